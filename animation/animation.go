@@ -0,0 +1,147 @@
+// Package animation turns keyframed views into per-frame render configs for
+// zoom sequences.
+package animation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// Ease shapes the progress between two keyframes.
+type Ease string
+
+const (
+	EaseLinear Ease = "linear"
+	EaseIn     Ease = "in"
+	EaseOut    Ease = "out"
+	EaseInOut  Ease = "inout"
+)
+
+func (e Ease) apply(t float64) (float64, error) {
+	switch e {
+	case EaseLinear, "":
+		return t, nil
+	case EaseIn:
+		return t * t, nil
+	case EaseOut:
+		return 1 - (1-t)*(1-t), nil
+	case EaseInOut:
+		return t * t * (3 - 2*t), nil
+	}
+	return 0, fmt.Errorf("invalid ease: %v", e)
+}
+
+// Keyframe pins the view at a frame. The Ease of a keyframe applies to the
+// segment that starts at it.
+type Keyframe struct {
+	Frame      int     `json:"frame"`
+	OffsetX    float64 `json:"x"`
+	OffsetY    float64 `json:"y"`
+	Zoom       float64 `json:"zoom"`
+	HueOffset  float64 `json:"hue"`
+	Iterations int     `json:"iterations,omitempty"`
+	Ease       Ease    `json:"ease,omitempty"`
+}
+
+// LoadKeyframes reads a JSON array of keyframes from filename.
+func LoadKeyframes(filename string) ([]Keyframe, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var keys []Keyframe
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid keyframes in %s: %w", filename, err)
+	}
+	return keys, nil
+}
+
+// Frames returns one config per frame, from the first to the last keyframe.
+//
+// Zoom is interpolated in log space so every frame zooms in by the same
+// factor, and the center moves in step with the zoom so the target does not
+// drift across the screen. Keyframes without Iterations get the base
+// iterations plus ramp extra iterations per doubling of zoom since the first
+// keyframe; iterations are then interpolated linearly.
+func Frames(base mandelbrot.Config, keys []Keyframe, ramp float64) ([]mandelbrot.Config, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keyframes")
+	}
+	keys = append([]Keyframe(nil), keys...)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Frame < keys[j].Frame })
+
+	for i := range keys {
+		if keys[i].Zoom <= 0 {
+			return nil, fmt.Errorf("keyframe %d: zoom must be positive", keys[i].Frame)
+		}
+		if i > 0 && keys[i].Frame == keys[i-1].Frame {
+			return nil, fmt.Errorf("keyframe %d: duplicate frame", keys[i].Frame)
+		}
+		if keys[i].Iterations == 0 {
+			iterations := base.MaxIterations
+			if iterations == 0 {
+				iterations = mandelbrot.DefaultConfig.MaxIterations
+			}
+			keys[i].Iterations = iterations + int(math.Round(ramp*math.Log2(keys[i].Zoom/keys[0].Zoom)))
+		}
+	}
+
+	frames := make([]mandelbrot.Config, 0, keys[len(keys)-1].Frame-keys[0].Frame+1)
+	for k := 0; k < len(keys); k++ {
+		from := keys[k]
+		if k == len(keys)-1 {
+			frames = append(frames, frameConfig(base, from, from, 0))
+			break
+		}
+		to := keys[k+1]
+		for f := from.Frame; f < to.Frame; f++ {
+			t, err := from.Ease.apply(float64(f-from.Frame) / float64(to.Frame-from.Frame))
+			if err != nil {
+				return nil, fmt.Errorf("keyframe %d: %w", from.Frame, err)
+			}
+			frames = append(frames, frameConfig(base, from, to, t))
+		}
+	}
+	return frames, nil
+}
+
+// CycleFrames returns frames configs that rotate the hue of base once around
+// the color wheel. The last frame stops one step short of a full turn, so the
+// sequence loops without repeating a frame.
+func CycleFrames(base mandelbrot.Config, frames int) []mandelbrot.Config {
+	configs := make([]mandelbrot.Config, frames)
+	for i := range configs {
+		configs[i] = base
+		configs[i].HueOffset = base.HueOffset + 360*float64(i)/float64(frames)
+	}
+	return configs
+}
+
+// frameConfig interpolates the view at progress t in [0, 1] between two keyframes
+func frameConfig(base mandelbrot.Config, from, to Keyframe, t float64) mandelbrot.Config {
+	cfg := base
+	cfg.Zoom = math.Exp(lerp(math.Log(from.Zoom), math.Log(to.Zoom), t))
+
+	// the visible span is proportional to 1/zoom, move the center by the
+	// fraction of the span change covered so far
+	w := t
+	if from.Zoom != to.Zoom {
+		w = (1/from.Zoom - 1/cfg.Zoom) / (1/from.Zoom - 1/to.Zoom)
+	}
+	cfg.OffsetX = lerp(from.OffsetX, to.OffsetX, w)
+	cfg.OffsetY = lerp(from.OffsetY, to.OffsetY, w)
+
+	cfg.HueOffset = lerp(from.HueOffset, to.HueOffset, t)
+	cfg.MaxIterations = int(math.Round(lerp(float64(from.Iterations), float64(to.Iterations), t)))
+	return cfg
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
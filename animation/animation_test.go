@@ -0,0 +1,94 @@
+package animation_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/animation"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+func TestFrames(t *testing.T) {
+	// zoom 1 to 16 to 4 over frames 0, 4 and 8; each ease applies to the
+	// first segment, the second stays linear
+	keys := func(ease animation.Ease) []animation.Keyframe {
+		return []animation.Keyframe{
+			{Frame: 8, Zoom: 4, OffsetX: 2, OffsetY: 0, HueOffset: 100},
+			{Frame: 0, Zoom: 1, Ease: ease},
+			{Frame: 4, Zoom: 16, OffsetX: 1, OffsetY: -2, HueOffset: 80},
+		}
+	}
+	type view struct {
+		frame                       int
+		zoom, offsetX, offsetY, hue float64
+		iterations                  int
+	}
+	// views every ease shares; with a ramp of 100 iterations per doubling,
+	// the keyframes get 1000, 1400 and 1200 iterations
+	shared := []view{
+		{0, 1, 0, 0, 0, 1000},
+		{4, 16, 1, -2, 80, 1400},
+		{8, 4, 2, 0, 100, 1200},
+		// halfway zooming out from 16 to 4 the zoom is 8, where 1/zoom has
+		// covered (1/16 - 1/8) / (1/16 - 1/4) = 1/3 of its change
+		{6, 8, 4. / 3, -4. / 3, 90, 1300},
+	}
+	tests := []struct {
+		ease animation.Ease
+		// the view halfway through the first segment, where the eased
+		// progress is 0.5 for linear and inout, 0.25 for in and 0.75 for out
+		middle view
+	}{
+		// the zoom is the geometric mean 4, where 1/zoom has covered
+		// (1 - 1/4) / (1 - 1/16) = 0.8 of its change
+		{animation.EaseLinear, view{2, 4, 0.8, -1.6, 40, 1200}},
+		{"", view{2, 4, 0.8, -1.6, 40, 1200}},
+		{animation.EaseInOut, view{2, 4, 0.8, -1.6, 40, 1200}},
+		// zoom 16^0.25 = 2, offsets at (1 - 1/2) / (1 - 1/16) = 8/15
+		{animation.EaseIn, view{2, 2, 8. / 15, -16. / 15, 20, 1100}},
+		// zoom 16^0.75 = 8, offsets at (1 - 1/8) / (1 - 1/16) = 14/15
+		{animation.EaseOut, view{2, 8, 14. / 15, -28. / 15, 60, 1300}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.ease), func(t *testing.T) {
+			base := mandelbrot.Config{MaxIterations: 1000, Width: 10}
+			frames, err := animation.Frames(base, keys(test.ease), 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frames) != 9 {
+				t.Fatalf("%d frames, want 9", len(frames))
+			}
+			for _, want := range append(shared[:len(shared):len(shared)], test.middle) {
+				got := frames[want.frame]
+				if !near(got.Zoom, want.zoom) || !near(got.OffsetX, want.offsetX) || !near(got.OffsetY, want.offsetY) ||
+					!near(got.HueOffset, want.hue) || got.MaxIterations != want.iterations {
+					t.Errorf("frame %d: zoom %g offset (%g, %g) hue %g iterations %d, want %+v",
+						want.frame, got.Zoom, got.OffsetX, got.OffsetY, got.HueOffset, got.MaxIterations, want)
+				}
+				if got.Width != base.Width {
+					t.Errorf("frame %d: width %d, want the base width %d", want.frame, got.Width, base.Width)
+				}
+			}
+		})
+	}
+}
+
+func TestFramesInvalid(t *testing.T) {
+	tests := map[string][]animation.Keyframe{
+		"none":      nil,
+		"zero zoom": {{Frame: 0, Zoom: 1}, {Frame: 5, Zoom: 0}},
+		"duplicate": {{Frame: 3, Zoom: 1}, {Frame: 3, Zoom: 2}},
+		"ease":      {{Frame: 0, Zoom: 1, Ease: "bounce"}, {Frame: 5, Zoom: 2}},
+	}
+	for name, keys := range tests {
+		if _, err := animation.Frames(mandelbrot.Config{}, keys, 0); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
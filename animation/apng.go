@@ -0,0 +1,117 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+var errNoFrames = errors.New("no frames to encode")
+
+// EncodeAPNG writes frames as a looping animated PNG that shows every frame
+// for delayNum/delayDen seconds. All frames must have the same bounds and pixel
+// type, so that they share the color type of the first frame.
+func EncodeAPNG(w io.Writer, frames []image.Image, delayNum, delayDen uint16) error {
+	if len(frames) == 0 {
+		return errNoFrames
+	}
+
+	if _, err := w.Write([]byte("\x89PNG\r\n\x1a\n")); err != nil {
+		return err
+	}
+
+	var ihdr []byte
+	sequence := uint32(0)
+	for i, frame := range frames {
+		chunks, err := pngChunks(frame)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		for _, c := range chunks {
+			switch c.kind {
+			case "IHDR":
+				if i == 0 {
+					ihdr = c.data
+					if err := writeChunk(w, "IHDR", ihdr); err != nil {
+						return err
+					}
+					// number of frames, loop forever
+					actl := binary.BigEndian.AppendUint32(nil, uint32(len(frames)))
+					actl = binary.BigEndian.AppendUint32(actl, 0)
+					if err := writeChunk(w, "acTL", actl); err != nil {
+						return err
+					}
+				} else if !bytes.Equal(c.data, ihdr) {
+					return errors.New("apng frames differ in size or color type")
+				}
+			case "IDAT":
+				data = append(data, c.data...)
+			}
+		}
+
+		bounds := frame.Bounds()
+		fctl := binary.BigEndian.AppendUint32(nil, sequence)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dx()))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dy()))
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint16(fctl, delayNum)
+		fctl = binary.BigEndian.AppendUint16(fctl, delayDen)
+		// dispose op none, blend op source
+		fctl = append(fctl, 0, 0)
+		if err := writeChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		sequence++
+
+		// the first frame is the default image, later ones go into fdAT chunks
+		if i == 0 {
+			err = writeChunk(w, "IDAT", data)
+		} else {
+			err = writeChunk(w, "fdAT", append(binary.BigEndian.AppendUint32(nil, sequence), data...))
+			sequence++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeChunk(w, "IEND", nil)
+}
+
+type chunk struct {
+	kind string
+	data []byte
+}
+
+// pngChunks encodes img as PNG and splits the result into chunks
+func pngChunks(img image.Image) ([]chunk, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()[8:]
+	chunks := []chunk{}
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		chunks = append(chunks, chunk{string(data[4:8]), data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) error {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	c = append(c, kind...)
+	c = append(c, data...)
+	c = binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+	_, err := w.Write(c)
+	return err
+}
@@ -0,0 +1,197 @@
+package animation
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"sort"
+)
+
+// paletteSize leaves one entry of the 256 color table for transparency
+const paletteSize = 255
+
+// maxPaletteSamples bounds the pixels looked at when building the palette
+const maxPaletteSamples = 1 << 20
+
+// EncodeGIF writes frames as a looping animated GIF with delay hundredths of a
+// second between frames. All frames share one palette built by median cut
+// over every frame, and after the first frame only the rectangle of pixels
+// that changed is stored, with unchanged pixels inside it left transparent.
+func EncodeGIF(w io.Writer, frames []image.Image, delay int) error {
+	if len(frames) == 0 {
+		return errNoFrames
+	}
+	bounds := frames[0].Bounds()
+
+	palette := medianCut(frames, paletteSize)
+	transparent := uint8(len(palette))
+	palette = append(palette, color.RGBA{})
+
+	indices := map[color.RGBA]uint8{}
+	index := func(c color.RGBA) uint8 {
+		if i, ok := indices[c]; ok {
+			return i
+		}
+		i := uint8(color.Palette(palette[:transparent]).Index(c))
+		indices[c] = i
+		return i
+	}
+
+	anim := &gif.GIF{LoopCount: 0}
+	var previous []uint8
+	for _, frame := range frames {
+		current := make([]uint8, bounds.Dx()*bounds.Dy())
+		for y := 0; y < bounds.Dy(); y++ {
+			for x := 0; x < bounds.Dx(); x++ {
+				current[y*bounds.Dx()+x] = index(rgba(frame.At(bounds.Min.X+x, bounds.Min.Y+y)))
+			}
+		}
+
+		// only store the part that differs from the previous frame
+		rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+		if previous != nil {
+			rect = changedRect(previous, current, bounds.Dx())
+			if rect.Empty() {
+				rect = image.Rect(0, 0, 1, 1)
+			}
+		}
+
+		paletted := image.NewPaletted(rect, palette)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				i := current[y*bounds.Dx()+x]
+				if previous != nil && previous[y*bounds.Dx()+x] == i {
+					i = transparent
+				}
+				paletted.SetColorIndex(x, y, i)
+			}
+		}
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+		previous = current
+	}
+
+	anim.Config = image.Config{
+		ColorModel: color.Palette(palette),
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// changedRect returns the bounding box of the indices that differ
+func changedRect(previous, current []uint8, width int) image.Rectangle {
+	rect := image.Rectangle{}
+	for i := range current {
+		if previous[i] != current[i] {
+			rect = rect.Union(image.Rect(i%width, i/width, i%width+1, i/width+1))
+		}
+	}
+	return rect
+}
+
+func rgba(c color.Color) color.RGBA {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+}
+
+// medianCut builds a palette of at most size colors by repeatedly splitting the
+// box of sampled colors with the widest channel range at its median
+func medianCut(frames []image.Image, size int) color.Palette {
+	total := 0
+	for _, frame := range frames {
+		total += frame.Bounds().Dx() * frame.Bounds().Dy()
+	}
+	step := total/maxPaletteSamples + 1
+
+	samples := []color.RGBA{}
+	n := 0
+	for _, frame := range frames {
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if n%step == 0 {
+					samples = append(samples, rgba(frame.At(x, y)))
+				}
+				n++
+			}
+		}
+	}
+
+	boxes := [][]color.RGBA{samples}
+	for len(boxes) < size {
+		// split the box with the widest range
+		widest, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				lo, hi := channelRange(box, c)
+				if hi-lo > spread {
+					widest, channel, spread = i, c, hi-lo
+				}
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.Slice(box, func(i, j int) bool { return channelOf(box[i], channel) < channelOf(box[j], channel) })
+		// cut at the median, moved to the nearest change of the channel so
+		// a color is never split over two boxes
+		mid := len(box) / 2
+		for mid < len(box) && channelOf(box[mid], channel) == channelOf(box[mid-1], channel) {
+			mid++
+		}
+		if mid == len(box) {
+			mid = len(box) / 2
+			for channelOf(box[mid], channel) == channelOf(box[mid-1], channel) {
+				mid--
+			}
+		}
+		boxes[widest] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var r, g, b int
+		for _, c := range box {
+			r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+		}
+		palette = append(palette, color.RGBA{uint8(r / len(box)), uint8(g / len(box)), uint8(b / len(box)), 0xff})
+	}
+	return palette
+}
+
+func channelRange(box []color.RGBA, channel int) (int, int) {
+	lo, hi := 255, 0
+	for _, c := range box {
+		v := channelOf(c, channel)
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	return lo, hi
+}
+
+func channelOf(c color.RGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	}
+	return int(c.B)
+}
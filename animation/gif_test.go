@@ -0,0 +1,142 @@
+package animation_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/animation"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// composite plays an animated GIF, returning what is on screen after each frame
+func composite(t *testing.T, anim *gif.GIF) []*image.RGBA {
+	t.Helper()
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	screens := []*image.RGBA{}
+	for i, frame := range anim.Image {
+		if !frame.Bounds().In(bounds) {
+			t.Fatalf("frame %d bounds %v outside %v", i, frame.Bounds(), bounds)
+		}
+		saved := image.NewRGBA(bounds)
+		draw.Draw(saved, bounds, canvas, image.Point{}, draw.Src)
+
+		// transparent pixels leave the previous frame showing
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		screen := image.NewRGBA(bounds)
+		draw.Draw(screen, bounds, canvas, image.Point{}, draw.Src)
+		screens = append(screens, screen)
+
+		switch anim.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+	return screens
+}
+
+// compareFrames returns the largest and the mean channel difference between
+// the screens and the frames
+func compareFrames(t *testing.T, screens []*image.RGBA, frames []image.Image) (largest int, mean float64) {
+	t.Helper()
+	if len(screens) != len(frames) {
+		t.Fatalf("%d frames, want %d", len(screens), len(frames))
+	}
+	total, n := 0, 0
+	for i, frame := range frames {
+		b := frame.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				got := screens[i].RGBAAt(x, y)
+				if got.A != 0xff {
+					t.Fatalf("frame %d pixel (%d, %d) has alpha %d", i, x, y, got.A)
+				}
+				r, g, bl, _ := frame.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				for _, d := range []int{int(got.R) - int(r>>8), int(got.G) - int(g>>8), int(got.B) - int(bl>>8)} {
+					if d < 0 {
+						d = -d
+					}
+					if d > largest {
+						largest = d
+					}
+					total += d
+					n++
+				}
+			}
+		}
+	}
+	return largest, float64(total) / float64(n)
+}
+
+func encodeDecodeGIF(t *testing.T, frames []image.Image) *gif.GIF {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := animation.EncodeGIF(&buf, frames, 4); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return anim
+}
+
+func TestGIFFewColors(t *testing.T) {
+	// fewer colors than the palette holds are kept exactly; a square moves
+	// over blocks of color, then stands still for a frame
+	frames := []image.Image{}
+	for _, offset := range []int{0, 3, 8, 8, 20} {
+		img := image.NewRGBA(image.Rect(0, 0, 48, 32))
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 48; x++ {
+				img.SetRGBA(x, y, color.RGBA{uint8(x / 4 * 20), uint8(y / 4 * 30), 90, 0xff})
+			}
+		}
+		square := image.Rect(offset, offset/2, offset+10, offset/2+10)
+		draw.Draw(img, square, image.NewUniform(color.RGBA{250, 20, 200, 0xff}), image.Point{}, draw.Src)
+		frames = append(frames, img)
+	}
+
+	anim := encodeDecodeGIF(t, frames)
+	if largest, _ := compareFrames(t, composite(t, anim), frames); largest != 0 {
+		t.Errorf("channels differ by up to %d", largest)
+	}
+	// later frames only store what changed
+	for i, frame := range anim.Image[1:] {
+		if frame.Bounds() == anim.Image[0].Bounds() {
+			t.Errorf("frame %d stores the whole image", i+1)
+		}
+	}
+}
+
+func TestGIFRender(t *testing.T) {
+	base := mandelbrot.Config{Width: 96, Height: 72, MaxIterations: 300, Smooth: true, Palette: mandelbrot.PaletteTwilight, Samples: 4}
+	configs, err := animation.Frames(base, []animation.Keyframe{
+		{Frame: 0, Zoom: 1},
+		{Frame: 4, Zoom: 3, OffsetX: 0.7435, OffsetY: -0.1315},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []image.Image{}
+	for _, config := range configs {
+		frame, _, err := output.Render(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+
+	largest, mean := compareFrames(t, composite(t, encodeDecodeGIF(t, frames)), frames)
+	// supersampled gradients have more colors than the 255 shared ones
+	if largest > 16 || mean > 1 {
+		t.Errorf("channels differ by up to %d, %.2f on average", largest, mean)
+	}
+}
@@ -0,0 +1,115 @@
+package animation
+
+import (
+	"image"
+	"math"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/utils"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// Renderer renders a single config and returns the config it was rendered
+// with, e.g. mandelbrot.Create followed by any post-processing.
+type Renderer func(cfg mandelbrot.Config) (image.Image, mandelbrot.Config, error)
+
+// Zoomer synthesizes zoom frames from keyframes instead of rendering every
+// frame. One keyframe is rendered per doubling of zoom, at Scale times the
+// frame resolution, and the frames up to the next doubling are cropped and
+// scaled down from it. With a Scale of 2 no frame is ever enlarged.
+//
+// Hue and iterations only change at keyframes, and frames whose view does not
+// fit inside the current keyframe, e.g. because the center moves quickly, are
+// rendered in full.
+type Zoomer struct {
+	Render Renderer
+	Scale  float64
+
+	base      float64
+	level     int
+	key       image.Image
+	keyConfig mandelbrot.Config
+}
+
+// NewZoomer returns a Zoomer that renders keyframes with render.
+func NewZoomer(render Renderer, scale float64) *Zoomer {
+	return &Zoomer{Render: render, Scale: scale}
+}
+
+// Frame returns the image for cfg, scaled from a keyframe where possible.
+func (z *Zoomer) Frame(cfg mandelbrot.Config) (image.Image, mandelbrot.Config, error) {
+	cfg = mandelbrot.Defaults(cfg)
+	if z.base == 0 {
+		z.base = cfg.Zoom
+	}
+
+	// keyframes sit at every doubling of zoom from the first frame
+	level := int(math.Floor(math.Log2(cfg.Zoom/z.base) + 1e-9))
+	if z.key == nil || level != z.level {
+		keyConfig := cfg
+		keyConfig.Zoom = z.base * math.Exp2(float64(level))
+		keyConfig.Width = int(math.Round(float64(cfg.Width) * z.Scale))
+		keyConfig.Height = int(math.Round(float64(cfg.Height) * z.Scale))
+
+		key, keyConfig, err := z.Render(keyConfig)
+		if err != nil {
+			return nil, cfg, err
+		}
+		z.key, z.keyConfig, z.level = key, keyConfig, level
+	}
+
+	s2d, ok := z.transform(cfg)
+	if !ok {
+		return z.Render(cfg)
+	}
+
+	dst := newLike(z.key, image.Rect(0, 0, cfg.Width, cfg.Height))
+	draw.CatmullRom.Transform(dst, s2d, z.key, z.key.Bounds(), draw.Src, nil)
+	cfg.HueOffset = z.keyConfig.HueOffset
+	cfg.MaxIterations = z.keyConfig.MaxIterations
+	return dst, cfg, nil
+}
+
+// newLike returns an image of the size r with the pixel type of img, so that
+// synthesized frames match rendered ones, e.g. when encoded as APNG
+func newLike(img image.Image, r image.Rectangle) draw.Image {
+	switch img.(type) {
+	case *image.NRGBA:
+		return image.NewNRGBA(r)
+	case *image.RGBA:
+		return image.NewRGBA(r)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(r)
+	case *utils.RGBF:
+		return utils.NewRGBF(r)
+	}
+	return image.NewRGBA64(r)
+}
+
+// transform returns the matrix mapping keyframe pixels to the pixels of cfg,
+// and false if the view of cfg is not covered by the keyframe.
+func (z *Zoomer) transform(cfg mandelbrot.Config) (f64.Aff3, bool) {
+	key := z.keyConfig
+	b := z.key.Bounds()
+
+	// a pixel index p of cfg is at index a*p + offset in the keyframe
+	axis := func(size, keySize int, scale *mandelbrot.SetScale, offset, keyOffset float64) (float64, float64, bool) {
+		span := scale.Max - scale.Min
+		a := key.Zoom / cfg.Zoom * float64(keySize) / float64(size)
+		o := (key.Zoom*(scale.Min/cfg.Zoom-offset+keyOffset) - scale.Min) * float64(keySize) / span
+		fits := o >= -0.5 && a*float64(size)+o <= float64(keySize)+0.5
+		// the same mapping between pixel centers, as used by draw
+		return a, o + 0.5 - 0.5*a, fits
+	}
+	ax, ox, fitsX := axis(cfg.Width, key.Width, cfg.XScale, cfg.OffsetX, key.OffsetX)
+	ay, oy, fitsY := axis(cfg.Height, key.Height, cfg.YScale, cfg.OffsetY, key.OffsetY)
+	if !fitsX || !fitsY {
+		return f64.Aff3{}, false
+	}
+
+	return f64.Aff3{
+		1 / ax, 0, -(ox + float64(b.Min.X)) / ax,
+		0, 1 / ay, -(oy + float64(b.Min.Y)) / ay,
+	}, true
+}
@@ -0,0 +1,68 @@
+package animation_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/animation"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+func TestZoomerFrames(t *testing.T) {
+	for _, depth := range []mandelbrot.Depth{mandelbrot.Depth8, mandelbrot.Depth16} {
+		for _, scale := range []float64{1, 2} {
+			t.Run(fmt.Sprintf("depth%d/scale%g", depth, scale), func(t *testing.T) {
+				// like animate -toX 0.7435 -toY -0.1315 -toZoom 1.5 -reuse
+				base := mandelbrot.Config{Width: 40, Height: 30, MaxIterations: 100, Depth: depth}
+				configs, err := animation.Frames(base, []animation.Keyframe{
+					{Frame: 0, Zoom: 1},
+					{Frame: 5, Zoom: 1.5, OffsetX: 0.7435, OffsetY: -0.1315},
+				}, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				zoomer := animation.NewZoomer(output.Render, scale)
+				frames := []image.Image{}
+				for _, config := range configs {
+					frame, _, err := zoomer.Frame(config)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if frame.Bounds() != image.Rect(0, 0, 40, 30) {
+						t.Fatalf("frame bounds %v, want 40x30", frame.Bounds())
+					}
+					if len(frames) > 0 && fmt.Sprintf("%T", frame) != fmt.Sprintf("%T", frames[0]) {
+						t.Fatalf("frame of type %T after one of %T", frame, frames[0])
+					}
+					frames = append(frames, frame)
+				}
+
+				var apng bytes.Buffer
+				if err := animation.EncodeAPNG(&apng, frames, 1, 25); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := png.Decode(&apng); err != nil {
+					t.Fatal(err)
+				}
+
+				var animated bytes.Buffer
+				if err := animation.EncodeGIF(&animated, frames, 4); err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := gif.DecodeAll(&animated)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(decoded.Image) != len(frames) {
+					t.Fatalf("%d gif frames, want %d", len(decoded.Image), len(frames))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package animation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// FrameWriter writes uncompressed video one frame at a time, so long
+// sequences never have to be held in memory or written as separate images.
+type FrameWriter interface {
+	WriteFrame(img image.Image) error
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+}
+
+var errFrameSize = errors.New("frames differ in size")
+
+// Y4MWriter writes YUV4MPEG2 video with 4:2:0 chroma subsampling and BT.601
+// limited range colors, which is what most encoders expect by default:
+//
+//	mandel animate -stream y4m -out - | ffmpeg -i - zoom.mp4
+type Y4MWriter struct {
+	w      *bufio.Writer
+	fps    int
+	bounds image.Rectangle
+}
+
+// NewY4MWriter returns a writer for video at fps frames per second. The frame
+// size is taken from the first frame.
+func NewY4MWriter(w io.Writer, fps int) *Y4MWriter {
+	return &Y4MWriter{w: bufio.NewWriter(w), fps: fps}
+}
+
+func (y *Y4MWriter) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	if y.bounds.Empty() {
+		y.bounds = bounds
+		_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg\n", bounds.Dx(), bounds.Dy(), y.fps)
+		if err != nil {
+			return err
+		}
+	} else if bounds.Size() != y.bounds.Size() {
+		return errFrameSize
+	}
+
+	width, height := bounds.Dx(), bounds.Dy()
+	chromaWidth, chromaHeight := (width+1)/2, (height+1)/2
+	luma := make([]byte, width*height)
+	cb := make([]float64, chromaWidth*chromaHeight)
+	cr := make([]float64, chromaWidth*chromaHeight)
+	count := make([]float64, chromaWidth*chromaHeight)
+
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			r16, g16, b16, _ := img.At(bounds.Min.X+px, bounds.Min.Y+py).RGBA()
+			r, g, b := float64(r16)/0xffff, float64(g16)/0xffff, float64(b16)/0xffff
+
+			luma[py*width+px] = byte(16 + 65.481*r + 128.553*g + 24.966*b + 0.5)
+			i := (py/2)*chromaWidth + px/2
+			cb[i] += 128 - 37.797*r - 74.203*g + 112.0*b
+			cr[i] += 128 + 112.0*r - 93.786*g - 18.214*b
+			count[i]++
+		}
+	}
+
+	chroma := make([]byte, 2*len(cb))
+	for i := range cb {
+		chroma[i] = byte(cb[i]/count[i] + 0.5)
+		chroma[len(cb)+i] = byte(cr[i]/count[i] + 0.5)
+	}
+
+	for _, data := range [][]byte{[]byte("FRAME\n"), luma, chroma} {
+		if _, err := y.w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (y *Y4MWriter) Flush() error {
+	return y.w.Flush()
+}
+
+// RawHeaderSize is the length of the header RawWriter writes before the
+// first frame.
+const RawHeaderSize = 32
+
+// RawWriter writes frames as packed 8-bit RGB after a single line header
+// naming the pixel format, size and frame rate in the style of YUV4MPEG2,
+// padded with spaces to RawHeaderSize bytes:
+//
+//	RGB24 W700 H700 F25:1
+//
+// Encoders reading raw video skip the header, e.g.
+//
+//	mandel animate -stream rgb -out - | ffmpeg -f rawvideo -pix_fmt rgb24 -s 700x700 -r 25 -skip_initial_bytes 32 -i - zoom.mp4
+type RawWriter struct {
+	w      *bufio.Writer
+	fps    int
+	bounds image.Rectangle
+}
+
+// NewRawWriter returns a writer for raw rgb24 video at fps frames per second.
+// The frame size is taken from the first frame.
+func NewRawWriter(w io.Writer, fps int) *RawWriter {
+	return &RawWriter{w: bufio.NewWriter(w), fps: fps}
+}
+
+func (raw *RawWriter) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	if raw.bounds.Empty() {
+		raw.bounds = bounds
+		header := fmt.Sprintf("RGB24 W%d H%d F%d:1", bounds.Dx(), bounds.Dy(), raw.fps)
+		if len(header) >= RawHeaderSize {
+			return fmt.Errorf("raw video header %q is longer than %d bytes", header, RawHeaderSize)
+		}
+		if _, err := fmt.Fprintf(raw.w, "%-*s\n", RawHeaderSize-1, header); err != nil {
+			return err
+		}
+	} else if bounds.Size() != raw.bounds.Size() {
+		return errFrameSize
+	}
+
+	row := make([]byte, 3*bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			i := 3 * (x - bounds.Min.X)
+			row[i], row[i+1], row[i+2] = byte(r>>8), byte(g>>8), byte(b>>8)
+		}
+		if _, err := raw.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (raw *RawWriter) Flush() error {
+	return raw.w.Flush()
+}
@@ -0,0 +1,106 @@
+package animation_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/animation"
+)
+
+func TestRawWriter(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	var buf bytes.Buffer
+	raw := animation.NewRawWriter(&buf, 25)
+	for i := 0; i < 2; i++ {
+		if err := raw.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := raw.WriteFrame(image.NewNRGBA(image.Rect(0, 0, 2, 3))); err == nil {
+		t.Error("no error for a frame of another size")
+	}
+	if err := raw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	header := "RGB24 W3 H2 F25:1" + strings.Repeat(" ", 14) + "\n"
+	if len(header) != animation.RawHeaderSize || !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("header %q, want %q", data[:animation.RawHeaderSize], header)
+	}
+	frames := data[animation.RawHeaderSize:]
+	if len(frames) != 2*3*2*3 {
+		t.Fatalf("%d bytes of frames, want %d", len(frames), 2*3*2*3)
+	}
+	for i := 0; i < 2*3*2; i++ {
+		c := img.At(i%3, i/3%2).(color.NRGBA)
+		if got := frames[3*i : 3*i+3]; !bytes.Equal(got, []byte{c.R, c.G, c.B}) {
+			t.Errorf("pixel %d is %v, want %v", i, got, []byte{c.R, c.G, c.B})
+		}
+	}
+}
+
+func TestY4MWriter(t *testing.T) {
+	// odd sizes round the subsampled chroma planes up
+	colors := []struct {
+		c         color.Color
+		y, cb, cr byte
+	}{
+		{color.White, 235, 128, 128},
+		{color.RGBA{0xff, 0, 0, 0xff}, 81, 90, 240},
+		{color.Black, 16, 128, 128},
+	}
+
+	var buf bytes.Buffer
+	y4m := animation.NewY4MWriter(&buf, 30)
+	for _, c := range colors {
+		img := image.NewRGBA(image.Rect(0, 0, 5, 3))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c.c), image.Point{}, draw.Src)
+		if err := y4m.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := y4m.WriteFrame(image.NewRGBA(image.Rect(0, 0, 3, 5))); err == nil {
+		t.Error("no error for a frame of another size")
+	}
+	if err := y4m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	header := "YUV4MPEG2 W5 H3 F30:1 Ip A1:1 C420jpeg\n"
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("header %q, want %q", data[:len(header)], header)
+	}
+	data = data[len(header):]
+
+	// a 5x3 luma plane, then 3x2 Cb and Cr planes
+	const lumaSize, chromaSize = 5 * 3, 3 * 2
+	frameSize := len("FRAME\n") + lumaSize + 2*chromaSize
+	if len(data) != len(colors)*frameSize {
+		t.Fatalf("%d bytes of frames, want %d", len(data), len(colors)*frameSize)
+	}
+	for i, c := range colors {
+		frame := data[i*frameSize : (i+1)*frameSize]
+		if !bytes.HasPrefix(frame, []byte("FRAME\n")) {
+			t.Fatalf("frame %d starts with %q", i, frame[:6])
+		}
+		planes := frame[len("FRAME\n"):]
+		want := append(bytes.Repeat([]byte{c.y}, lumaSize), bytes.Repeat([]byte{c.cb}, chromaSize)...)
+		want = append(want, bytes.Repeat([]byte{c.cr}, chromaSize)...)
+		if !bytes.Equal(planes, want) {
+			t.Errorf("frame %d is %v, want %v", i, planes, want)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 #!/bin/sh
 
-go run cmd/cmd.go \
+go run ./cmd render \
     --width=2048 \
     --height=2048 \
     --out ./img/colored.jpg \
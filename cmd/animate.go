@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AksAman/mandelbrot/animation"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// runAnimate renders a zoom sequence to numbered frames, e.g. mandelbrot_0000.png,
+// or to a single animated file when -out ends in .gif or .apng, or streams it
+// as uncompressed video with -stream.
+// The config flags describe the first frame, the to* flags the last one.
+// With -cycle the view stays put and only the hue rotates.
+func runAnimate(args []string) {
+	fs := newFlagSet("animate")
+
+	var (
+		frames    = fs.Int("frames", 60, "Number of frames")
+		toX       = fs.Float64("toX", 0, "Offset X of the last frame (default offsetX)")
+		toY       = fs.Float64("toY", 0, "Offset Y of the last frame (default offsetY)")
+		toZoom    = fs.Float64("toZoom", 1000, "Zoom of the last frame")
+		toHue     = fs.Float64("toHue", 0, "Hue offset of the last frame (default hue)")
+		toIter    = fs.Int("toIter", 0, "Max Iterations of the last frame (default iter plus the ramp)")
+		ease      = fs.String("ease", string(animation.EaseInOut), "Easing between keyframes (options: linear, in, out, inout)")
+		ramp      = fs.Float64("ramp", 0, "Extra iterations per doubling of zoom for keyframes without iterations")
+		keyframes = fs.String("keyframes", "", "JSON file with a list of keyframes, replaces the start and end flags")
+		fps       = fs.Int("fps", 25, "Frames per second of animated .gif, .apng and streamed output")
+		cycle     = fs.Bool("cycle", false, "Rotate the hue once around the color wheel instead of zooming, iterating the view only once")
+		reuse     = fs.Bool("reuse", false, "Render a keyframe every doubling of zoom and scale it down for the frames in between")
+		keyScale  = fs.Float64("keyScale", 2, "Resolution of -reuse keyframes relative to the frames")
+		stream    = fs.String("stream", "", "Stream frames as uncompressed video to out, or to stdout when out is - (options: y4m, rgb; default y4m for .y4m files)")
+	)
+	fs.Parse(args)
+
+	base, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var configs []mandelbrot.Config
+	renderFrame := animation.Renderer(output.Render)
+	if *cycle {
+		if *keyframes != "" || *reuse {
+			log.Fatal("-cycle cannot be combined with -keyframes or -reuse")
+		}
+		if *frames < 2 {
+			log.Fatal("an animation needs at least 2 frames")
+		}
+		configs = animation.CycleFrames(base, *frames)
+		renderFrame, err = cycleRenderer(base)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		var keys []animation.Keyframe
+		if *keyframes != "" {
+			keys, err = animation.LoadKeyframes(*keyframes)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			if *frames < 2 {
+				log.Fatal("an animation needs at least 2 frames")
+			}
+			set := map[string]bool{}
+			fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+			start := animation.Keyframe{
+				Frame:      0,
+				OffsetX:    base.OffsetX,
+				OffsetY:    base.OffsetY,
+				Zoom:       base.Zoom,
+				HueOffset:  base.HueOffset,
+				Iterations: base.MaxIterations,
+				Ease:       animation.Ease(*ease),
+			}
+			end := start
+			end.Frame = *frames - 1
+			end.Zoom = *toZoom
+			end.Iterations = *toIter
+			if set["toX"] {
+				end.OffsetX = *toX
+			}
+			if set["toY"] {
+				end.OffsetY = *toY
+			}
+			if set["toHue"] {
+				end.HueOffset = *toHue
+			}
+			keys = []animation.Keyframe{start, end}
+		}
+
+		configs, err = animation.Frames(base, keys, *ramp)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *reuse {
+			if *keyScale < 1 {
+				log.Fatal("keyScale must be at least 1")
+			}
+			renderFrame = animation.NewZoomer(output.Render, *keyScale).Frame
+		}
+	}
+
+	if *fps < 1 {
+		log.Fatal("fps must be at least 1")
+	}
+
+	ext := strings.ToLower(filepath.Ext(*out))
+	if *stream == "" && ext == ".y4m" {
+		*stream = "y4m"
+	}
+	if *stream != "" {
+		if err := streamFrames(configs, renderFrame, *out, *stream, *fps); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if dir := filepath.Dir(*out); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	animated := ext == ".gif" || ext == ".apng"
+
+	tStart := time.Now()
+	images := []image.Image{}
+	for i, config := range configs {
+		img, finalConfig, err := renderFrame(config)
+		if err != nil {
+			log.Fatalf("frame %d: %v", i, err)
+		}
+		if animated {
+			images = append(images, img)
+			log.Printf("Rendered frame %d/%d (zoom %g, iterations %d)\n", i+1, len(configs), finalConfig.Zoom, finalConfig.MaxIterations)
+			continue
+		}
+		filename := frameFilename(*out, i)
+		if err := SaveImage(img, filename, finalConfig); err != nil {
+			log.Fatalf("frame %d: %v", i, err)
+		}
+		log.Printf("Saved frame %d/%d to %s (zoom %g, iterations %d)\n", i+1, len(configs), filename, finalConfig.Zoom, finalConfig.MaxIterations)
+	}
+	log.Printf("Time taken to render %d frames: %s\n", len(configs), time.Since(tStart))
+
+	if animated {
+		tStart = time.Now()
+		if err := saveAnimation(images, *out, *fps); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Time taken to save animation %s: %s\n", *out, time.Since(tStart))
+	}
+}
+
+// streamFrames renders configs one at a time and writes them as uncompressed
+// video to filename, which may be a named pipe, or to stdout for "-". Frames
+// are never held in memory or written to disk.
+func streamFrames(configs []mandelbrot.Config, render animation.Renderer, filename, format string, fps int) error {
+	var w io.Writer = os.Stdout
+	if filename != "-" {
+		// no O_TRUNC or MkdirAll, so that a named pipe is opened as is
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+		}
+		w = f
+	}
+
+	var video animation.FrameWriter
+	switch format {
+	case "y4m":
+		video = animation.NewY4MWriter(w, fps)
+	case "rgb":
+		video = animation.NewRawWriter(w, fps)
+	default:
+		return fmt.Errorf("invalid stream format: %v", format)
+	}
+
+	tStart := time.Now()
+	for i, config := range configs {
+		img, finalConfig, err := render(config)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		if i == 0 && format == "rgb" {
+			size := img.Bounds().Size()
+			log.Printf("Streaming raw video, read it with: -f rawvideo -pix_fmt rgb24 -s %dx%d -r %d -skip_initial_bytes %d -i %s\n", size.X, size.Y, fps, animation.RawHeaderSize, filename)
+		}
+		if err := video.WriteFrame(img); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		if err := video.Flush(); err != nil {
+			return err
+		}
+		log.Printf("Streamed frame %d/%d (zoom %g, iterations %d)\n", i+1, len(configs), finalConfig.Zoom, finalConfig.MaxIterations)
+	}
+	log.Printf("Time taken to stream %d frames: %s\n", len(configs), time.Since(tStart))
+	return nil
+}
+
+// cycleRenderer iterates base once and recolors the stored field for the hue
+// offset of every frame
+func cycleRenderer(base mandelbrot.Config) (animation.Renderer, error) {
+	field, err := mandelbrot.NewField(base)
+	if err != nil {
+		return nil, err
+	}
+	return func(config mandelbrot.Config) (image.Image, mandelbrot.Config, error) {
+		finalConfig := field.Config
+		finalConfig.HueOffset = config.HueOffset
+		return output.Finish(field.Image(config.HueOffset), finalConfig), finalConfig, nil
+	}, nil
+}
+
+// saveAnimation writes frames to a single animated .gif or .apng file
+func saveAnimation(frames []image.Image, filename string, fps int) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".gif" {
+		// gif delays are in hundredths of a second
+		delay := int(math.Round(100 / float64(fps)))
+		return animation.EncodeGIF(f, frames, delay)
+	}
+	return animation.EncodeAPNG(f, frames, 1, uint16(fps))
+}
+
+// frameFilename numbers filename for frame i, frames/zoom.png becomes frames/zoom_0007.png
+func frameFilename(filename string, i int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s_%04d%s", strings.TrimSuffix(filename, ext), i, ext)
+}
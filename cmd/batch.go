@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// batchEntry is one view of a manifest
+type batchEntry struct {
+	line int
+	// out is the output file, empty to name it after -out and the parameters
+	out string
+	// fields are the config overrides as a JSON object
+	fields []byte
+}
+
+// batchResult is the outcome of one entry, written to the -report file
+type batchResult struct {
+	Line    int     `json:"line"`
+	Out     string  `json:"out,omitempty"`
+	Status  string  `json:"status"`
+	Seconds float64 `json:"seconds,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+const (
+	statusRendered = "rendered"
+	statusSkipped  = "skipped"
+	statusFailed   = "failed"
+	// statusPending marks views not started before an interrupt
+	statusPending = "pending"
+)
+
+// runBatch renders every view of a manifest on top of the config flags.
+//
+// Outputs that already exist are skipped and images are written under a
+// temporary name first, so running the same manifest again resumes an
+// interrupted batch. On interrupt no new renders are started and the ones in
+// progress are finished.
+func runBatch(args []string) {
+	fs := newFlagSet("batch")
+	manifest := fs.String("manifest", "", "JSONL or .csv file of config overrides, one view per line, with an optional out field")
+	jobs := fs.Int("jobs", 2, "Number of views rendered at the same time")
+	force := fs.Bool("force", false, "Render views whose output already exists")
+	report := fs.String("report", "", "Write the result of every view to this JSONL file")
+	fs.Parse(args)
+
+	if *manifest == "" {
+		log.Fatal("batch needs a -manifest")
+	}
+	if *jobs < 1 {
+		log.Fatal("jobs must be at least 1")
+	}
+
+	base, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := readManifest(*manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := resolveOutputs(base, entries); err != nil {
+		log.Fatalf("invalid manifest %s: %v", *manifest, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tStart := time.Now()
+	jobChan := make(chan batchEntry)
+	results := make([]batchResult, len(entries))
+	index := map[int]int{}
+	for i, entry := range entries {
+		index[entry.line] = i
+		results[i] = batchResult{Line: entry.line, Out: entry.out, Status: statusPending}
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(*jobs)
+	mu := &sync.Mutex{}
+	for w := 0; w < *jobs; w++ {
+		go func() {
+			defer wg.Done()
+			for entry := range jobChan {
+				result := renderEntry(base, entry, *force)
+				switch result.Status {
+				case statusFailed:
+					log.Printf("Line %d failed: %s\n", result.Line, result.Error)
+				case statusSkipped:
+					log.Printf("Line %d skipped, %s exists\n", result.Line, result.Out)
+				default:
+					log.Printf("Line %d saved to %s in %.2fs\n", result.Line, result.Out, result.Seconds)
+				}
+				mu.Lock()
+				results[index[entry.line]] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, entry := range entries {
+		select {
+		case jobChan <- entry:
+		case <-ctx.Done():
+			log.Println("Interrupted, waiting for renders in progress")
+			break dispatch
+		}
+	}
+	close(jobChan)
+	wg.Wait()
+
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	log.Printf("Batch of %d views done in %s: %d rendered, %d skipped, %d failed, %d pending\n",
+		len(results), time.Since(tStart), counts[statusRendered], counts[statusSkipped], counts[statusFailed], counts[statusPending])
+
+	if *report != "" {
+		if err := writeReport(*report, results); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if counts[statusFailed] > 0 || ctx.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// renderEntry renders and saves one view unless its output exists
+func renderEntry(base mandelbrot.Config, entry batchEntry, force bool) batchResult {
+	result := batchResult{Line: entry.line, Status: statusFailed}
+
+	config, err := mandelbrot.Override(base, entry.fields)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Out = entry.out
+	if _, err := os.Stat(result.Out); err == nil && !force {
+		result.Status = statusSkipped
+		return result
+	}
+
+	tStart := time.Now()
+	img, finalConfig, err := output.Render(config)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// a file under the final name is always complete, the temporary one is
+	// unique so entries rendering at once never share it
+	dir := filepath.Dir(result.Out)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	tmp, err := os.CreateTemp(dir, ".partial-*"+filepath.Ext(result.Out))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	tmp.Close()
+	partial := tmp.Name()
+	// temporary files are private, images get the usual permissions
+	if err := os.Chmod(partial, 0o644); err != nil {
+		os.Remove(partial)
+		result.Error = err.Error()
+		return result
+	}
+	if err := SaveImage(img, partial, finalConfig); err != nil {
+		os.Remove(partial)
+		result.Error = err.Error()
+		return result
+	}
+	if err := os.Rename(partial, result.Out); err != nil {
+		os.Remove(partial)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = statusRendered
+	result.Seconds = time.Since(tStart).Seconds()
+	return result
+}
+
+// resolveOutputs names the output of entries without one after -out and
+// their parameters, and rejects outputs shared by several entries, which
+// would overwrite each other. Entries with invalid fields are left to fail
+// when rendered.
+func resolveOutputs(base mandelbrot.Config, entries []batchEntry) error {
+	lines := map[string]int{}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.out == "" {
+			config, err := mandelbrot.Override(base, entry.fields)
+			if err != nil {
+				continue
+			}
+			entry.out = output.Filename(*out, mandelbrot.Defaults(config))
+		}
+		key := filepath.Clean(entry.out)
+		if line, ok := lines[key]; ok {
+			return fmt.Errorf("lines %d and %d both write %s", line, entry.line, entry.out)
+		}
+		lines[key] = entry.line
+	}
+	return nil
+}
+
+// readManifest reads a CSV manifest for .csv files and JSONL otherwise
+func readManifest(filename string) ([]batchEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []batchEntry
+	if strings.ToLower(filepath.Ext(filename)) == ".csv" {
+		entries, err = readCSVManifest(f)
+	} else {
+		entries, err = readJSONLManifest(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filename, err)
+	}
+	return entries, nil
+}
+
+// readJSONLManifest reads one JSON object of config fields per line, blank
+// lines and lines starting with # are ignored
+func readJSONLManifest(r io.Reader) ([]batchEntry, error) {
+	entries := []batchEntry{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entry, err := newBatchEntry(line, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// readCSVManifest reads a header of config field names and one view per row.
+// Numbers and booleans are converted, empty cells leave the field unset.
+func readCSVManifest(r io.Reader) ([]batchEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []batchEntry{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		fields := map[string]json.RawMessage{}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			// anything but JSON numbers and booleans is a string
+			_, err := strconv.ParseFloat(value, 64)
+			isNumber := err == nil && json.Valid([]byte(value))
+			if !isNumber && value != "true" && value != "false" {
+				value = strconv.Quote(value)
+			}
+			fields[strings.TrimSpace(header[i])] = json.RawMessage(value)
+		}
+		entry, err := newBatchEntry(line, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// newBatchEntry separates the out field from the config fields
+func newBatchEntry(line int, fields map[string]json.RawMessage) (batchEntry, error) {
+	entry := batchEntry{line: line}
+	for key, value := range fields {
+		if strings.EqualFold(key, "out") {
+			if err := json.Unmarshal(value, &entry.out); err != nil {
+				return batchEntry{}, fmt.Errorf("invalid out: %w", err)
+			}
+			delete(fields, key)
+		}
+	}
+
+	var err error
+	entry.fields, err = json.Marshal(fields)
+	return entry, err
+}
+
+func writeReport(filename string, results []batchResult) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// benchResult is the measurement of one mode on one view
+type benchResult struct {
+	Mode            mandelbrot.Mode `json:"mode"`
+	Width           int             `json:"width"`
+	Height          int             `json:"height"`
+	Zoom            float64         `json:"zoom"`
+	Workers         int             `json:"workers"`
+	Seconds         float64         `json:"seconds"`
+	PixelsPerSecond float64         `json:"pixelsPerSecond"`
+	AllocsPerRender uint64          `json:"allocsPerRender"`
+	BytesPerRender  uint64          `json:"bytesPerRender"`
+	// Speedup is relative to the seq mode on the same view
+	Speedup float64 `json:"speedup"`
+	// Efficiency is the speedup per goroutine that can run in parallel
+	Efficiency float64 `json:"efficiency"`
+}
+
+// runBench renders the view of the config flags in every mode, size, zoom and
+// worker count and prints the best time of each next to pixels per second,
+// allocations and the speedup over the seq mode
+func runBench(args []string) {
+	fs := newFlagSet("bench")
+	var (
+		modes        = fs.String("modes", "seq,pixel,row,workers", "Comma separated modes to compare")
+		sizes        = fs.String("sizes", "", "Comma separated image sizes as WxH or N for NxN (default width x height)")
+		zooms        = fs.String("zooms", "", "Comma separated zooms (default zoom)")
+		workerCounts = fs.String("workerCounts", "", "Comma separated worker counts for the workers mode (default workers)")
+		runs         = fs.Int("runs", 3, "Renders per case, the fastest counts")
+		asJSON       = fs.Bool("json", false, "Print the results as JSON instead of a table")
+	)
+	fs.Parse(args)
+
+	if *runs < 1 {
+		log.Fatal("runs must be at least 1")
+	}
+
+	config, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	final := mandelbrot.Defaults(config)
+	if *sizes == "" {
+		*sizes = fmt.Sprintf("%dx%d", final.Width, final.Height)
+	}
+	if *zooms == "" {
+		*zooms = strconv.FormatFloat(final.Zoom, 'g', -1, 64)
+	}
+	if *workerCounts == "" {
+		*workerCounts = strconv.Itoa(final.Workers)
+	}
+	// sizes are given after scaling
+	config.Scale = 1
+
+	results := []benchResult{}
+	for _, size := range strings.Split(*sizes, ",") {
+		w, h, err := parseSize(size)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, zoom := range strings.Split(*zooms, ",") {
+			z, err := strconv.ParseFloat(strings.TrimSpace(zoom), 64)
+			if err != nil {
+				log.Fatalf("invalid zoom %q", zoom)
+			}
+			view := config
+			view.Width, view.Height, view.Zoom = w, h, z
+			view.Mode, view.Workers = mandelbrot.Sequential, 1
+
+			baseline, err := benchmark(view, *runs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, mode := range strings.Split(*modes, ",") {
+				view.Mode = mandelbrot.Mode(strings.TrimSpace(mode))
+				counts := []string{"1"}
+				if view.Mode == mandelbrot.Parallel {
+					counts = strings.Split(*workerCounts, ",")
+				}
+				for _, count := range counts {
+					if view.Workers, err = strconv.Atoi(strings.TrimSpace(count)); err != nil || view.Workers < 1 {
+						log.Fatalf("invalid worker count %q", count)
+					}
+					result := baseline
+					if view.Mode != mandelbrot.Sequential {
+						if result, err = benchmark(view, *runs); err != nil {
+							log.Fatal(err)
+						}
+					}
+					result.Speedup = baseline.Seconds / result.Seconds
+					result.Efficiency = result.Speedup / float64(parallelism(view))
+					results = append(results, result)
+				}
+			}
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "mode\tsize\tzoom\tworkers\ttime\tMpixels/s\tallocs\tbytes\tspeedup\tefficiency\t\n")
+	for _, r := range results {
+		workers := "-"
+		if r.Mode == mandelbrot.Parallel {
+			workers = strconv.Itoa(r.Workers)
+		}
+		fmt.Fprintf(tw, "%s\t%dx%d\t%g\t%s\t%s\t%.2f\t%d\t%d\t%.2fx\t%.0f%%\t\n",
+			r.Mode, r.Width, r.Height, r.Zoom, workers, time.Duration(r.Seconds*float64(time.Second)).Round(time.Microsecond),
+			r.PixelsPerSecond/1e6, r.AllocsPerRender, r.BytesPerRender, r.Speedup, 100*r.Efficiency)
+	}
+	tw.Flush()
+	fmt.Printf("\nGOMAXPROCS %d, %s/%s\n", runtime.GOMAXPROCS(0), runtime.GOOS, runtime.GOARCH)
+}
+
+// benchmark renders config runs times and returns the fastest time together
+// with the average allocations per render
+func benchmark(config mandelbrot.Config, runs int) (benchResult, error) {
+	var before, after runtime.MemStats
+	best := time.Duration(0)
+	var mallocs, bytes uint64
+	for i := 0; i < runs; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		tStart := time.Now()
+		if _, err := mandelbrot.Create(config); err != nil {
+			return benchResult{}, err
+		}
+		d := time.Since(tStart)
+		runtime.ReadMemStats(&after)
+
+		mallocs += after.Mallocs - before.Mallocs
+		bytes += after.TotalAlloc - before.TotalAlloc
+		if best == 0 || d < best {
+			best = d
+		}
+	}
+
+	return benchResult{
+		Mode:            config.Mode,
+		Width:           config.Width,
+		Height:          config.Height,
+		Zoom:            config.Zoom,
+		Workers:         config.Workers,
+		Seconds:         best.Seconds(),
+		PixelsPerSecond: float64(config.Width*config.Height) / best.Seconds(),
+		AllocsPerRender: mallocs / uint64(runs),
+		BytesPerRender:  bytes / uint64(runs),
+	}, nil
+}
+
+// parallelism returns the number of goroutines of a mode that can run at once
+func parallelism(config mandelbrot.Config) int {
+	switch config.Mode {
+	case mandelbrot.Sequential:
+		return 1
+	case mandelbrot.Parallel:
+		if config.Workers < runtime.GOMAXPROCS(0) {
+			return config.Workers
+		}
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parseSize parses WxH, or N for a square
+func parseSize(size string) (int, int, error) {
+	size = strings.TrimSpace(size)
+	w, h, found := strings.Cut(size, "x")
+	if !found {
+		h = w
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("invalid size %q", size)
+	}
+	return width, height, nil
+}
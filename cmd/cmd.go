@@ -5,64 +5,142 @@ import (
 	"flag"
 	"fmt"
 	"image"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/AksAman/mandelbrot/imageio"
 	"github.com/AksAman/mandelbrot/mandelbrot"
-	"github.com/AksAman/mandelbrot/utils"
-	"github.com/disintegration/imaging"
+	"github.com/AksAman/mandelbrot/output"
 )
 
 var (
-	out        = flag.String("out", "mandelbrot.png", "Name of the output file with extension")
-	iterations = flag.Int("iter", mandelbrot.DefaultConfig.MaxIterations, "Max Iterations")
-	width      = flag.Int("width", mandelbrot.DefaultConfig.Width, "Width of the image")
-	height     = flag.Int("height", mandelbrot.DefaultConfig.Height, "Height of the image")
-	threshold  = flag.Float64("threshold", mandelbrot.DefaultConfig.Threshold, "Threshold for the mandelbrot set")
-	workers    = flag.Int("workers", mandelbrot.DefaultConfig.Workers, "Number of workers to use")
-	scale      = flag.Int("scale", mandelbrot.DefaultConfig.Scale, "Scale of the image")
-	mode       = flag.String("mode", string(mandelbrot.DefaultConfig.Mode), "Mode of the image (options: seq, pixel, row, workers)")
-	zoom       = flag.Float64("zoom", mandelbrot.DefaultConfig.Zoom, "Zoom of the image")
-	hueOffset  = flag.Float64("hue", mandelbrot.DefaultConfig.HueOffset, "Hue offset of the image")
-	offsetX    = flag.Float64("offsetX", mandelbrot.DefaultConfig.OffsetX, "Offset X of the image")
-	offsetY    = flag.Float64("offsetY", mandelbrot.DefaultConfig.OffsetY, "Offset Y of the image")
-	jpgQuality = flag.Int("quality", 100, "JPG Quality")
+	out         = flag.String("out", "mandelbrot.png", "Name of the output file with extension")
+	iterations  = flag.Int("iter", mandelbrot.DefaultConfig.MaxIterations, "Max Iterations")
+	width       = flag.Int("width", mandelbrot.DefaultConfig.Width, "Width of the image")
+	height      = flag.Int("height", mandelbrot.DefaultConfig.Height, "Height of the image")
+	threshold   = flag.Float64("threshold", mandelbrot.DefaultConfig.Threshold, "Threshold for the mandelbrot set")
+	workers     = flag.Int("workers", mandelbrot.DefaultConfig.Workers, "Number of workers to use")
+	scale       = flag.Int("scale", mandelbrot.DefaultConfig.Scale, "Scale of the image")
+	mode        = flag.String("mode", string(mandelbrot.DefaultConfig.Mode), "Mode of the image (options: seq, pixel, row, workers)")
+	zoom        = flag.Float64("zoom", mandelbrot.DefaultConfig.Zoom, "Zoom of the image")
+	hueOffset   = flag.Float64("hue", mandelbrot.DefaultConfig.HueOffset, "Hue offset of the image")
+	palette     = flag.String("palette", string(mandelbrot.DefaultConfig.Palette), "Colors the hue maps to (options: hsv, fire, ocean, twilight, gray)")
+	colors      = flag.String("colors", "", "Comma separated #rrggbb stops of a custom gradient, used instead of -palette")
+	offsetX     = flag.Float64("offsetX", mandelbrot.DefaultConfig.OffsetX, "Offset X of the image")
+	offsetY     = flag.Float64("offsetY", mandelbrot.DefaultConfig.OffsetY, "Offset Y of the image")
+	interior    = flag.String("interior", string(mandelbrot.DefaultConfig.Interior), "Coloring of points inside the set (options: white, black, modulus, period, multiplier, distance)")
+	samples     = flag.Int("samples", mandelbrot.DefaultConfig.Samples, "Sub-pixel samples per axis for anti-aliasing, 1 disables it")
+	sampling    = flag.String("sampling", string(mandelbrot.DefaultConfig.Sampling), "Placement of sub-pixel samples (options: grid, jitter)")
+	filter      = flag.String("filter", string(mandelbrot.DefaultConfig.Filter), "Anti-aliasing filter (options: box, gaussian, lanczos)")
+	adaptive    = flag.Float64("adaptive", mandelbrot.DefaultConfig.Adaptive, "Only supersample pixels whose neighbors differ by more than this fraction of a color channel, 0 supersamples all")
+	depth       = flag.Int("depth", int(mandelbrot.DefaultConfig.Depth), "Bits per channel (options: 8, 16, 32 for linear float without banding, e.g. for .hdr), contrast is only adjusted for 8")
+	jpgQuality  = flag.Int("quality", 100, "JPG Quality")
+	compression = flag.String("compression", string(imageio.CompressionLZW), "TIFF compression (options: none, lzw, deflate)")
+	fromImage   = flag.String("from-image", "", "Render with the parameters embedded in this image or its name, flags that are set explicitly override them")
+	configFile  = flag.String("config", "", "JSON file with config fields, applied after -from-image, flags that are set explicitly override them")
+	preset      = flag.String("preset", "", "Named preset of the -config file to apply on top of its top level fields")
 )
 
+// flagFields copies the value of each config flag into a config
+var flagFields = map[string]func(cfg *mandelbrot.Config){
+	"iter":      func(cfg *mandelbrot.Config) { cfg.MaxIterations = *iterations },
+	"width":     func(cfg *mandelbrot.Config) { cfg.Width = *width },
+	"height":    func(cfg *mandelbrot.Config) { cfg.Height = *height },
+	"threshold": func(cfg *mandelbrot.Config) { cfg.Threshold = *threshold },
+	"workers":   func(cfg *mandelbrot.Config) { cfg.Workers = *workers },
+	"scale":     func(cfg *mandelbrot.Config) { cfg.Scale = *scale },
+	"mode":      func(cfg *mandelbrot.Config) { cfg.Mode = mandelbrot.Mode(*mode) },
+	"zoom":      func(cfg *mandelbrot.Config) { cfg.Zoom = *zoom },
+	"hue":       func(cfg *mandelbrot.Config) { cfg.HueOffset = *hueOffset },
+	"palette":   func(cfg *mandelbrot.Config) { cfg.Palette = mandelbrot.Palette(*palette) },
+	"colors":    func(cfg *mandelbrot.Config) { cfg.Colors = splitColors(*colors) },
+	"offsetX":   func(cfg *mandelbrot.Config) { cfg.OffsetX = *offsetX },
+	"offsetY":   func(cfg *mandelbrot.Config) { cfg.OffsetY = *offsetY },
+	"interior":  func(cfg *mandelbrot.Config) { cfg.Interior = mandelbrot.Interior(*interior) },
+	"samples":   func(cfg *mandelbrot.Config) { cfg.Samples = *samples },
+	"sampling":  func(cfg *mandelbrot.Config) { cfg.Sampling = mandelbrot.Sampling(*sampling) },
+	"filter":    func(cfg *mandelbrot.Config) { cfg.Filter = mandelbrot.Filter(*filter) },
+	"adaptive":  func(cfg *mandelbrot.Config) { cfg.Adaptive = *adaptive },
+	"depth":     func(cfg *mandelbrot.Config) { cfg.Depth = mandelbrot.Depth(*depth) },
+}
+
+// command is a subcommand of the tool
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
+
+var commands = []command{
+	{"render", "Render a single image, the default when no command is given", runRender},
+	{"animate", "Render a zoom or color cycling animation", runAnimate},
+	{"batch", "Render the views of a JSONL or CSV manifest, resuming where a previous run stopped", runBatch},
+	{"explore", "Search a view for interesting regions and render the best ones", runExplore},
+	{"tile", "Render a large image as a grid of separate tiles", runTile},
+	{"bench", "Compare the render time of the modes", runBench},
+	{"serve", "Serve rendered images over HTTP", runServe},
+	{"info", "Print the coordinates, pixel size and precision of a view", runInfo},
+}
+
 func main() {
-	flag.Parse()
+	name, args := "render", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			c.run(args)
+			return
+		}
+	}
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", filepath.Base(os.Args[0]))
+}
+
+// newFlagSet returns the flag set of a subcommand, sharing the config and
+// output flags so every command reads the same defaults
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\nFlags:\n", filepath.Base(os.Args[0]), name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runRender renders a single image to -out, named after its parameters
+func runRender(args []string) {
+	fs := newFlagSet("render")
+	fs.Parse(args)
 
-	// loop()
 	tStart := time.Now()
-	config := mandelbrot.Config{
-		Width:         *width,
-		Height:        *height,
-		Threshold:     *threshold,
-		Workers:       *workers,
-		Scale:         *scale,
-		Mode:          mandelbrot.Mode(*mode),
-		MaxIterations: *iterations,
-		Zoom:          *zoom,
-		HueOffset:     *hueOffset,
-		OffsetX:       *offsetX,
-		OffsetY:       *offsetY,
-		Smooth:        true,
+	config, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	img, err := mandelbrot.Create(config)
+	img, finalConfig, err := output.Render(config)
 	if err != nil {
 		log.Fatal(err)
 	}
-	finalConfig := img.(*mandelbrot.Mandelbrot).Config
-	img = imaging.AdjustContrast(img, 2)
 
 	tTaken := time.Since(tStart)
 
@@ -70,7 +148,7 @@ func main() {
 
 	tStart = time.Now()
 
-	err = SaveImage(img, GetFilenameWithFlags(*out, finalConfig))
+	err = SaveImage(img, output.Filename(*out, finalConfig), finalConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -78,110 +156,56 @@ func main() {
 	log.Printf("Time taken to save image: %s\n", tTaken)
 }
 
-func loop() {
+// baseConfig returns the config described by the config flags, starting from
+// the parameters of -from-image and the -config file when they are set. Only
+// flags set explicitly on fs override those.
+func baseConfig(fs *flag.FlagSet) (mandelbrot.Config, error) {
 	config := mandelbrot.Config{
-		Width:         *width,
-		Height:        *height,
-		Threshold:     *threshold,
-		Workers:       *workers,
-		Scale:         *scale,
-		Mode:          mandelbrot.Mode(*mode),
-		MaxIterations: *iterations,
-		Zoom:          *zoom,
-		HueOffset:     *hueOffset,
-		OffsetX:       0,
-		OffsetY:       0,
-		Smooth:        true,
+		Smooth: true,
 	}
-	ext := filepath.Ext(*out)
-	fnameWithoutExt := strings.Split(*out, ext)[0]
-
-	workers := 8
-	type workerJob struct {
-		i, j   int
-		config mandelbrot.Config
+	for _, set := range flagFields {
+		set(&config)
 	}
-	jobChan := make(chan workerJob)
-
-	wg := &sync.WaitGroup{}
-	wg.Add(workers)
-
-	// create workers with jobs waiting on channel
-	for w := 0; w < workers; w++ {
-		go func() {
-			defer wg.Done()
-			for job := range jobChan {
-				img, err := mandelbrot.Create(job.config)
-				if err != nil {
-					log.Println(job.i, job.j, err)
-					continue
-				}
-
-				sum := utils.SumOfAllPixels(img)
-				if sum == 0 {
-					log.Println(job.i, job.j, "sum is 0")
-					continue
-				}
-				// log.Println(job.i, job.j, "sum is", sum)
-
-				img = imaging.AdjustContrast(img, 2)
-
-				fname := fmt.Sprintf("%s_%d_%d.%v", fnameWithoutExt, job.i, job.j, ext)
-				fname = GetFilenameWithFlags(fname, config)
-				err = SaveImage(img, fname)
-				if err != nil {
-					log.Println(job.i, job.j, err)
-					continue
-				}
-			}
-		}()
+	if *preset != "" && *configFile == "" {
+		return mandelbrot.Config{}, errors.New("-preset needs a -config file")
+	}
+	if *fromImage == "" && *configFile == "" {
+		return config, config.Validate()
 	}
 
-	for i := -*width; i < *width; i++ {
-		for j := -*height; j < *height; j++ {
-			config.OffsetX = float64(i) / float64(*width)
-			config.OffsetY = float64(j) / float64(*height)
-			jobChan <- workerJob{i, j, config}
+	var err error
+	if *fromImage != "" {
+		config, err = output.Load(*fromImage)
+		if err != nil {
+			return mandelbrot.Config{}, err
+		}
+	}
+	if *configFile != "" {
+		config, err = mandelbrot.LoadConfig(*configFile, *preset, config)
+		if err != nil {
+			return mandelbrot.Config{}, err
 		}
 	}
-	close(jobChan)
-	wg.Wait()
-}
-
-func GetFilenameWithFlags(filename string, config mandelbrot.Config) string {
-	ext := filepath.Ext(filename)
-	filenameWithoutExt := strings.Split(filename, ext)[0]
-	return filenameWithoutExt + "#" + getFlags(config) + ext
-}
 
-func getFlags(config mandelbrot.Config) string {
-	return strings.Join([]string{
-		"i=" + strconv.Itoa(config.MaxIterations),
-		"t=" + fmt.Sprintf("%v", config.Threshold),
-		"z=" + fmt.Sprintf("%v", config.Zoom),
-		// "h=" + fmt.Sprintf("%v", *hueOffset),
-		"x=" + fmt.Sprintf("%v", config.OffsetX),
-		"y=" + fmt.Sprintf("%v", config.OffsetY),
-	}, "_")
+	fs.Visit(func(f *flag.Flag) {
+		if set, ok := flagFields[f.Name]; ok {
+			set(&config)
+		}
+	})
+	return config, config.Validate()
 }
 
-func SaveImage(img image.Image, filename string) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	switch ext := filepath.Ext(filename); ext {
-	case ".png":
-		err = png.Encode(f, img)
-	case ".jpg", ".jpeg":
-		err = jpeg.Encode(f, img, &jpeg.Options{Quality: *jpgQuality})
-	case ".gif":
-		err = gif.Encode(f, img, &gif.Options{NumColors: 256})
-	default:
-		err = errors.New("Unsupported image format: " + ext)
+// splitColors splits the -colors flag, empty for no custom gradient
+func splitColors(colors string) []string {
+	if colors == "" {
+		return nil
 	}
+	return strings.Split(colors, ",")
+}
 
-	return err
+func SaveImage(img image.Image, filename string, config mandelbrot.Config) error {
+	return output.Save(img, filename, config, imageio.Options{
+		Quality:     *jpgQuality,
+		Compression: imageio.Compression(*compression),
+	})
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AksAman/mandelbrot/explore"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// runExplore searches the view of the config flags for interesting regions
+// and renders the best ones at full size. Their configs are written as a
+// batch manifest, so the selection can be rendered again at other sizes.
+func runExplore(args []string) {
+	fs := newFlagSet("explore")
+	var (
+		grid     = fs.Int("grid", explore.DefaultOptions.Grid, "Split every view into grid x grid candidates, zooming in by grid per step")
+		steps    = fs.Int("steps", explore.DefaultOptions.Steps, "Number of zoom steps")
+		beam     = fs.Int("beam", explore.DefaultOptions.Beam, "Number of best views per step that are searched further")
+		preview  = fs.Int("preview", explore.DefaultOptions.Preview, "Longer side of the previews that are scored, in pixels")
+		ramp     = fs.Float64("ramp", 0, "Extra iterations per doubling of zoom")
+		top      = fs.Int("top", 10, "Number of best views to render, views close to a better one are left out")
+		manifest = fs.String("manifest", "", "Write the configs of the rendered views to this JSONL file (default out with a .jsonl extension)")
+	)
+	fs.Parse(args)
+
+	root, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tStart := time.Now()
+	views, err := explore.Explore(root, explore.Options{
+		Grid:    *grid,
+		Steps:   *steps,
+		Beam:    *beam,
+		Preview: *preview,
+		Ramp:    *ramp,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Time taken to score %d views: %s\n", len(views), time.Since(tStart))
+	views = explore.Top(views, *top)
+
+	if dir := filepath.Dir(*out); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *manifest == "" {
+		*manifest = strings.TrimSuffix(*out, filepath.Ext(*out)) + ".jsonl"
+	}
+	f, err := os.Create(*manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "rank\tscore\tentropy\tedges\tboundary\tstep\tzoom\tcenter\tfile\n")
+	for i, view := range views {
+		img, finalConfig, err := output.Render(view.Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filename := output.Filename(*out, finalConfig)
+		if err := SaveImage(img, filename, finalConfig); err != nil {
+			log.Fatal(err)
+		}
+		if err := writeManifestLine(f, finalConfig, filename); err != nil {
+			log.Fatal(err)
+		}
+
+		s := view.Score
+		fmt.Fprintf(tw, "%d\t%.3f\t%.3f\t%.3f\t%.3f\t%d\t%g\t%.10g %+.10gi\t%s\n",
+			i+1, s.Total, s.Entropy, s.Edges, s.Boundary, view.Step, finalConfig.Zoom,
+			-finalConfig.OffsetX, -finalConfig.OffsetY, filename)
+	}
+	tw.Flush()
+	log.Printf("Wrote the configs of %d views to %s\n", len(views), *manifest)
+}
+
+// writeManifestLine writes cfg and its output file as a line of a batch manifest
+func writeManifestLine(f *os.File, cfg mandelbrot.Config, filename string) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	fields["out"] = filename
+	return json.NewEncoder(f).Encode(fields)
+}
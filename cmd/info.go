@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// float64Bits is the precision of the coordinates used when iterating
+const float64Bits = 53
+
+// runInfo prints where the view of the config flags lies in the complex plane
+// and whether float64 can still tell its pixels apart
+func runInfo(args []string) {
+	fs := newFlagSet("info")
+	fs.Parse(args)
+
+	config, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := mandelbrot.Defaults(config)
+	res := cfg.Estimate()
+
+	// the mapping of mandelbrot.Create, from pixel to point
+	xMin, xMax := cfg.XScale.Min/cfg.Zoom-cfg.OffsetX, cfg.XScale.Max/cfg.Zoom-cfg.OffsetX
+	yMin, yMax := cfg.YScale.Min/cfg.Zoom-cfg.OffsetY, cfg.YScale.Max/cfg.Zoom-cfg.OffsetY
+	pixelX := (xMax - xMin) / float64(cfg.Width)
+	pixelY := (yMax - yMin) / float64(cfg.Height)
+	pixel := math.Min(math.Abs(pixelX), math.Abs(pixelY))
+
+	// bits needed so that neighboring pixels map to different coordinates
+	magnitude := math.Max(math.Max(math.Abs(xMin), math.Abs(xMax)), math.Max(math.Abs(yMin), math.Abs(yMax)))
+	bits := int(math.Ceil(math.Log2(magnitude / pixel)))
+	digits := int(math.Ceil(math.Log10(magnitude / pixel)))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "size\t%dx%d\n", cfg.Width, cfg.Height)
+	fmt.Fprintf(tw, "zoom\t%g\n", cfg.Zoom)
+	fmt.Fprintf(tw, "center\t%.17g %+.17gi\n", (xMin+xMax)/2, (yMin+yMax)/2)
+	fmt.Fprintf(tw, "real\t%.17g to %.17g\n", xMin, xMax)
+	fmt.Fprintf(tw, "imaginary\t%.17g to %.17g\n", yMin, yMax)
+	fmt.Fprintf(tw, "pixel size\t%.6g x %.6g\n", math.Abs(pixelX), math.Abs(pixelY))
+	fmt.Fprintf(tw, "precision\t%d bits, %d significant digits\n", bits, digits)
+	fmt.Fprintf(tw, "memory\t%.1f MB for the pixel buffer\n", float64(res.Bytes)/1e6)
+	fmt.Fprintf(tw, "samples\t%d\n", res.Samples)
+	fmt.Fprintf(tw, "iterations\t%.3g estimated, at most %.3g\n", float64(res.Iterations), float64(res.MaxIterations))
+	fmt.Fprintf(tw, "goroutines\t%d\n", res.Goroutines)
+	tw.Flush()
+
+	if bits > float64Bits {
+		fmt.Printf("\nfloat64 has %d bits, neighboring pixels share coordinates and the image will be blocky.\n", float64Bits)
+	}
+	if err := cfg.ValidateSize(); err != nil {
+		fmt.Printf("\n%v, render it with tile.\n", err)
+	}
+}
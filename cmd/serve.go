@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/web"
+)
+
+// runServe serves rendered images, the config and output flags are the
+// defaults for query parameters that requests leave out
+func runServe(args []string) {
+	fs := newFlagSet("serve")
+	port := fs.String("port", "8080", "Port to run the server on")
+	maxPixels := fs.Int64("maxPixels", 4096*4096, "Largest image a request may render, after scaling, 0 for no limit")
+	maxSamples := fs.Int64("maxSamples", 4*4096*4096, "Most points a request may iterate, pixels times sub-pixel samples, 0 for no limit")
+	maxIterations := fs.Int("maxIterations", 100000, "Most iterations a request may ask for, 0 for no limit")
+	maxGoroutines := fs.Int("maxGoroutines", 4096, "Most goroutines a render may start, 0 for no limit")
+	renders := fs.Int("renders", runtime.GOMAXPROCS(0), "Renders running at once, 0 for no limit")
+	queue := fs.Int("queue", 16, "Requests waiting for a render, more get 503")
+	queueTimeout := fs.Duration("queueTimeout", 30*time.Second, "Longest wait for a render before a 503, 0 to wait until the client leaves")
+	rate := fs.Float64("rate", 2, "Requests per second per client on average, more get 429, 0 for no limit")
+	burst := fs.Int("burst", 10, "Requests a client may make at once")
+	tileRate := fs.Float64("tileRate", 20, "Tile requests per second per client on average, more get 429, 0 for no limit")
+	tileBurst := fs.Int("tileBurst", 100, "Tile requests a client may make at once, a screen of tiles")
+	cacheMemory := fs.Int64("cacheMemory", 256, "Megabytes of rendered images kept in memory, 0 disables the cache")
+	cacheDir := fs.String("cacheDir", "", "Directory keeping rendered images evicted from memory, across restarts")
+	cacheDisk := fs.Int64("cacheDisk", 1024, "Megabytes of rendered images kept in -cacheDir")
+	cacheMaxAge := fs.Duration("cacheMaxAge", time.Hour, "How long browsers and proxies may reuse an image before revalidating it")
+	fs.Parse(args)
+
+	defaults, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cache *web.Cache
+	if *cacheMemory > 0 || *cacheDir != "" {
+		cache, err = web.NewCache(*cacheMemory<<20, *cacheDir, *cacheDisk<<20)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	handler := web.Handler(web.Options{
+		Defaults:    defaults,
+		Out:         *out,
+		Quality:     *jpgQuality,
+		Compression: imageio.Compression(*compression),
+		Cache:       cache,
+		CacheMaxAge: *cacheMaxAge,
+		Limits: web.Limits{
+			MaxPixels:     *maxPixels,
+			MaxSamples:    *maxSamples,
+			MaxIterations: *maxIterations,
+			MaxGoroutines: *maxGoroutines,
+			Renders:       *renders,
+			Queue:         *queue,
+			QueueTimeout:  *queueTimeout,
+			Rate:          *rate,
+			Burst:         *burst,
+			TileRate:      *tileRate,
+			TileBurst:     *tileBurst,
+		},
+	})
+
+	addr := fmt.Sprintf(":%s", *port)
+	log.Printf("Server running on port %s", addr)
+	log.Fatal(http.ListenAndServe(addr, handler))
+}
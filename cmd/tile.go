@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// runTile renders the image described by the config flags as a grid of
+// tiles, e.g. mandelbrot_002_003.png for row 2 and column 3, so images larger
+// than memory can be rendered and stitched afterwards. Every tile carries its
+// own region in its metadata.
+func runTile(args []string) {
+	fs := newFlagSet("tile")
+	size := fs.Int("tileSize", 1024, "Width and height of the tiles in pixels, tiles on the right and bottom edge may be smaller")
+	fs.Parse(args)
+
+	if *size < 1 {
+		log.Fatal("tileSize must be at least 1")
+	}
+
+	config, err := baseConfig(fs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config = mandelbrot.Defaults(config)
+
+	if dir := filepath.Dir(*out); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rows := (config.Height + *size - 1) / *size
+	cols := (config.Width + *size - 1) / *size
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+
+	tStart := time.Now()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			r := image.Rect(col**size, row**size, (col+1)**size, (row+1)**size).Intersect(bounds)
+			img, finalConfig, err := output.Render(config.Region(r))
+			if err != nil {
+				log.Fatalf("tile %d,%d: %v", row, col, err)
+			}
+			filename := tileFilename(*out, row, col)
+			if err := SaveImage(img, filename, finalConfig); err != nil {
+				log.Fatalf("tile %d,%d: %v", row, col, err)
+			}
+			log.Printf("Saved tile %d/%d to %s\n", row*cols+col+1, rows*cols, filename)
+		}
+	}
+	log.Printf("Time taken to render %d tiles: %s\n", rows*cols, time.Since(tStart))
+}
+
+// tileFilename numbers filename for the tile at row and col, tiles/big.png
+// becomes tiles/big_002_003.png
+func tileFilename(filename string, row, col int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s_%03d_%03d%s", strings.TrimSuffix(filename, ext), row, col, ext)
+}
@@ -0,0 +1,228 @@
+// Package explore searches for visually interesting views by scoring small
+// previews and zooming into the best parts of them.
+package explore
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// Score measures how interesting a view looks, every part is in [0, 1].
+type Score struct {
+	// Entropy of the color histogram, 0 for a single color
+	Entropy float64
+	// Edges is the fraction of pixels that differ clearly from a neighbor
+	Edges float64
+	// Boundary is the fraction of pixels next to the other side of the set
+	// boundary, scaled so a boundary through a quarter of the pixels scores 1
+	Boundary float64
+	// Total is the mean of the other parts
+	Total float64
+}
+
+// View is a scored candidate view.
+type View struct {
+	Config mandelbrot.Config
+	Score  Score
+	// Step is the number of zoom steps from the starting view
+	Step int
+}
+
+// Options control the search.
+type Options struct {
+	// Grid splits every view into Grid x Grid candidates, zooming in by Grid
+	Grid int
+	// Steps is the number of zoom steps
+	Steps int
+	// Beam is the number of best views per step that are searched further
+	Beam int
+	// Preview is the longer side of the previews that are scored, in pixels
+	Preview int
+	// Ramp adds iterations per doubling of zoom
+	Ramp float64
+}
+
+// DefaultOptions searches four steps deep, zooming in 3x per step.
+var DefaultOptions = Options{
+	Grid:    3,
+	Steps:   4,
+	Beam:    4,
+	Preview: 96,
+}
+
+// edgeThreshold is the luminance difference that counts as an edge
+const edgeThreshold = 0.1
+
+// Explore scores the sub-views of root and recursively of the best scoring
+// ones, and returns all scored views, best first.
+func Explore(root mandelbrot.Config, opts Options) ([]View, error) {
+	if opts.Grid < 2 || opts.Steps < 1 || opts.Beam < 1 || opts.Preview < 8 {
+		return nil, errors.New("explore needs a grid of at least 2, steps and beam of at least 1 and previews of at least 8 pixels")
+	}
+	root = mandelbrot.Defaults(root)
+
+	views := []View{}
+	parents := []mandelbrot.Config{root}
+	for step := 1; step <= opts.Steps; step++ {
+		level := []View{}
+		for _, parent := range parents {
+			for _, cfg := range Subviews(parent, opts.Grid) {
+				cfg.MaxIterations = parent.MaxIterations + int(math.Round(opts.Ramp*math.Log2(float64(opts.Grid))))
+				score, err := Measure(cfg, opts.Preview)
+				if err != nil {
+					return nil, err
+				}
+				level = append(level, View{Config: cfg, Score: score, Step: step})
+			}
+		}
+		sortViews(level)
+		views = append(views, level...)
+
+		parents = parents[:0]
+		for i := 0; i < len(level) && i < opts.Beam; i++ {
+			parents = append(parents, level[i].Config)
+		}
+	}
+
+	sortViews(views)
+	return views, nil
+}
+
+// Top returns the n best of views sorted best first, skipping views whose
+// center is less than twice the size of the wider of the two views away from
+// a better one, so the result does not show the same place twice.
+func Top(views []View, n int) []View {
+	top := []View{}
+	for _, view := range views {
+		if len(top) == n {
+			break
+		}
+		near := false
+		for _, better := range top {
+			if nearby(view.Config, better.Config) {
+				near = true
+				break
+			}
+		}
+		if !near {
+			top = append(top, view)
+		}
+	}
+	return top
+}
+
+func nearby(a, b mandelbrot.Config) bool {
+	ax, ay, aw, ah := bounds(a)
+	bx, by, bw, bh := bounds(b)
+	return math.Abs(ax-bx) < 2*math.Max(aw, bw) && math.Abs(ay-by) < 2*math.Max(ah, bh)
+}
+
+// bounds returns the center and size of the view of cfg in the complex plane
+func bounds(cfg mandelbrot.Config) (float64, float64, float64, float64) {
+	cfg = mandelbrot.Defaults(cfg)
+	xMin, xMax := cfg.XScale.Min/cfg.Zoom-cfg.OffsetX, cfg.XScale.Max/cfg.Zoom-cfg.OffsetX
+	yMin, yMax := cfg.YScale.Min/cfg.Zoom-cfg.OffsetY, cfg.YScale.Max/cfg.Zoom-cfg.OffsetY
+	return (xMin + xMax) / 2, (yMin + yMax) / 2, math.Abs(xMax - xMin), math.Abs(yMax - yMin)
+}
+
+// Subviews splits the view of cfg into a grid x grid raster of views of the
+// same size, row by row.
+func Subviews(cfg mandelbrot.Config, grid int) []mandelbrot.Config {
+	cfg = mandelbrot.Defaults(cfg)
+	xMin, xMax := cfg.XScale.Min/cfg.Zoom-cfg.OffsetX, cfg.XScale.Max/cfg.Zoom-cfg.OffsetX
+	yMin, yMax := cfg.YScale.Min/cfg.Zoom-cfg.OffsetY, cfg.YScale.Max/cfg.Zoom-cfg.OffsetY
+
+	views := make([]mandelbrot.Config, 0, grid*grid)
+	for j := 0; j < grid; j++ {
+		for i := 0; i < grid; i++ {
+			view := cfg
+			view.Zoom = cfg.Zoom * float64(grid)
+			// keep the cell center in the middle of the scales
+			cx := xMin + (float64(i)+0.5)*(xMax-xMin)/float64(grid)
+			cy := yMin + (float64(j)+0.5)*(yMax-yMin)/float64(grid)
+			view.OffsetX = (cfg.XScale.Min+cfg.XScale.Max)/2/view.Zoom - cx
+			view.OffsetY = (cfg.YScale.Min+cfg.YScale.Max)/2/view.Zoom - cy
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
+// Measure scores the view of cfg on a preview whose longer side is size pixels.
+func Measure(cfg mandelbrot.Config, size int) (Score, error) {
+	cfg = mandelbrot.Defaults(cfg)
+	if cfg.Width >= cfg.Height {
+		cfg.Height = utils.Max(1, cfg.Height*size/cfg.Width)
+		cfg.Width = size
+	} else {
+		cfg.Width = utils.Max(1, cfg.Width*size/cfg.Height)
+		cfg.Height = size
+	}
+
+	field, err := mandelbrot.NewField(cfg)
+	if err != nil {
+		return Score{}, err
+	}
+	img := field.Image(cfg.HueOffset)
+
+	histogram := map[uint16]int{}
+	edges, boundary := 0, 0
+	for y := 0; y < cfg.Height; y++ {
+		for x := 0; x < cfg.Width; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			histogram[uint16(r>>12)<<8|uint16(g>>12)<<4|uint16(b>>12)]++
+
+			edge, across := false, false
+			for _, n := range [][2]int{{x + 1, y}, {x, y + 1}, {x - 1, y}, {x, y - 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= cfg.Width || n[1] >= cfg.Height {
+					continue
+				}
+				if math.Abs(luminance(img, x, y)-luminance(img, n[0], n[1])) > edgeThreshold {
+					edge = true
+				}
+				if field.Escaped(x, y) != field.Escaped(n[0], n[1]) {
+					across = true
+				}
+			}
+			if edge {
+				edges++
+			}
+			if across {
+				boundary++
+			}
+		}
+	}
+
+	pixels := float64(cfg.Width * cfg.Height)
+	entropy := 0.
+	for _, count := range histogram {
+		p := float64(count) / pixels
+		entropy -= p * math.Log2(p)
+	}
+	// the most colors a preview can show
+	if bins := math.Log2(math.Min(pixels, 1<<12)); bins > 0 {
+		entropy /= bins
+	}
+
+	score := Score{
+		Entropy:  entropy,
+		Edges:    float64(edges) / pixels,
+		Boundary: math.Min(1, 4*float64(boundary)/pixels),
+	}
+	score.Total = (score.Entropy + score.Edges + score.Boundary) / 3
+	return score, nil
+}
+
+func luminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 0xffff
+}
+
+func sortViews(views []View) {
+	sort.SliceStable(views, func(i, j int) bool { return views[i].Score.Total > views[j].Score.Total })
+}
@@ -2,6 +2,7 @@ module github.com/AksAman/mandelbrot
 
 go 1.19
 
-require github.com/disintegration/imaging v1.6.2
-
-require golang.org/x/image v0.0.0-20220902085622-e7cb96979f69 // indirect
+require (
+	github.com/disintegration/imaging v1.6.2
+	golang.org/x/image v0.0.0-20220902085622-e7cb96979f69
+)
@@ -0,0 +1,67 @@
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// floatImage is implemented by images that keep linear light float channels,
+// such as *utils.RGBF
+type floatImage interface {
+	image.Image
+	RGBFAt(x, y int) (float32, float32, float32)
+}
+
+// EncodeHDR writes img as an uncompressed Radiance RGBE (.hdr) file.
+// Images with float channels are written as is, anything else is assumed to
+// be gamma encoded sRGB and converted to linear light.
+func EncodeHDR(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	_, err := fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", bounds.Dy(), bounds.Dx())
+	if err != nil {
+		return err
+	}
+
+	fimg, isFloat := img.(floatImage)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var r, g, b float32
+			if isFloat {
+				r, g, b = fimg.RGBFAt(x, y)
+			} else {
+				sr, sg, sb, _ := img.At(x, y).RGBA()
+				r = float32(utils.SrgbToLinear(float64(sr) / 0xffff))
+				g = float32(utils.SrgbToLinear(float64(sg) / 0xffff))
+				b = float32(utils.SrgbToLinear(float64(sb) / 0xffff))
+			}
+			if _, err := bw.Write(rgbe(r, g, b)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// rgbe packs three channels into shared exponent form
+func rgbe(r, g, b float32) []byte {
+	v := math.Max(float64(r), math.Max(float64(g), float64(b)))
+	if v < 1e-32 {
+		return []byte{0, 0, 0, 0}
+	}
+	frac, exp := math.Frexp(v)
+	scale := frac * 256 / v
+	return []byte{
+		byte(float64(r) * scale),
+		byte(float64(g) * scale),
+		byte(float64(b) * scale),
+		byte(exp + 128),
+	}
+}
@@ -0,0 +1,259 @@
+package imageio_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/utils"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// noise returns an opaque image of random pixels, 8 or 16 bits per channel.
+// Random data barely compresses, so large ones fill the LZW code table.
+func noise(width, height, bits int) image.Image {
+	rng := rand.New(rand.NewSource(int64(width*height + bits)))
+	bounds := image.Rect(0, 0, width, height)
+	if bits == 16 {
+		img := image.NewRGBA64(bounds)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.SetRGBA64(x, y, color.RGBA64{uint16(rng.Intn(1 << 16)), uint16(rng.Intn(1 << 16)), uint16(rng.Intn(1 << 16)), 0xffff})
+			}
+		}
+		return img
+	}
+	img := image.NewNRGBA(bounds)
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+// sameRGB fails t unless got has the size and RGB values of want
+func sameRGB(t *testing.T, got, want image.Image) {
+	t.Helper()
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("size %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+	g, w := got.Bounds().Min, want.Bounds().Min
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			gr, gg, gb, _ := got.At(g.X+x, g.Y+y).RGBA()
+			wr, wg, wb, _ := want.At(w.X+x, w.Y+y).RGBA()
+			if gr != wr || gg != wg || gb != wb {
+				t.Fatalf("pixel (%d, %d) is %04x %04x %04x, want %04x %04x %04x", x, y, gr, gg, gb, wr, wg, wb)
+			}
+		}
+	}
+}
+
+func TestTIFFRoundTrip(t *testing.T) {
+	sizes := []struct{ width, height int }{
+		{1, 1},
+		{7, 5},
+		// 180000 random bytes need far more than the 4094 codes of a table
+		{300, 200},
+	}
+	compressions := []imageio.Compression{imageio.CompressionNone, imageio.CompressionLZW, imageio.CompressionDeflate}
+
+	for _, bits := range []int{8, 16} {
+		for _, compression := range compressions {
+			for _, size := range sizes {
+				name := fmt.Sprintf("%d-bit/%s/%dx%d", bits, compression, size.width, size.height)
+				t.Run(name, func(t *testing.T) {
+					img := noise(size.width, size.height, bits)
+					var buf bytes.Buffer
+					if err := imageio.Encode(&buf, img, ".tiff", imageio.Options{Compression: compression}); err != nil {
+						t.Fatal(err)
+					}
+					decoded, err := tiff.Decode(&buf)
+					if err != nil {
+						t.Fatal(err)
+					}
+					sameRGB(t, decoded, img)
+				})
+			}
+		}
+	}
+}
+
+func TestTIFFRoundTripCompressible(t *testing.T) {
+	// long runs build long table entries, which random data never does
+	img := image.NewNRGBA(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x / 64), uint8(y / 3), uint8((x ^ y) & 0xf0), 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := imageio.Encode(&buf, img, ".tif", imageio.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := tiff.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameRGB(t, decoded, img)
+}
+
+func TestTIFFUnsupportedCompression(t *testing.T) {
+	err := imageio.Encode(io.Discard, noise(2, 2, 8), ".tiff", imageio.Options{Compression: "zip"})
+	if err == nil {
+		t.Fatal("no error for an unknown compression")
+	}
+}
+
+func TestBMPRoundTrip(t *testing.T) {
+	for _, bits := range []int{8, 16} {
+		t.Run(fmt.Sprintf("%d-bit", bits), func(t *testing.T) {
+			img := noise(13, 9, bits)
+			var buf bytes.Buffer
+			if err := imageio.Encode(&buf, img, ".bmp", imageio.Options{}); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := bmp.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// BMP only has 8 bits per channel
+			want := image.NewNRGBA(img.Bounds())
+			draw.Draw(want, want.Bounds(), img, image.Point{}, draw.Src)
+			sameRGB(t, decoded, want)
+		})
+	}
+}
+
+// readPNM parses a binary PPM or PGM as written by the encoders, returning
+// the samples of every pixel
+func readPNM(t *testing.T, data []byte) (magic string, width, height, maxval int, samples []int) {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(data))
+	if _, err := fmt.Fscanln(r, &magic); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if b, _ := r.Peek(1); len(b) == 0 || b[0] != '#' {
+			break
+		}
+		if _, err := r.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fmt.Fscan(r, &width, &height, &maxval); err != nil {
+		t.Fatal(err)
+	}
+	// a single whitespace byte separates the header from the samples
+	r.ReadByte()
+	rest, _ := io.ReadAll(r)
+
+	size := 1
+	if maxval > 255 {
+		size = 2
+	}
+	for i := 0; i+size <= len(rest); i += size {
+		if size == 2 {
+			samples = append(samples, int(binary.BigEndian.Uint16(rest[i:])))
+		} else {
+			samples = append(samples, int(rest[i]))
+		}
+	}
+	return magic, width, height, maxval, samples
+}
+
+func TestPNM(t *testing.T) {
+	tests := []struct {
+		ext    string
+		bits   int
+		magic  string
+		maxval int
+	}{
+		{".ppm", 8, "P6", 255},
+		{".ppm", 16, "P6", 65535},
+		{".pgm", 8, "P5", 255},
+		{".pgm", 16, "P5", 65535},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%d-bit", test.ext, test.bits), func(t *testing.T) {
+			img := noise(5, 3, test.bits)
+			var buf bytes.Buffer
+			opts := imageio.Options{Metadata: map[string]string{"Config": "a\nb"}}
+			if err := imageio.Encode(&buf, img, test.ext, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			magic, width, height, maxval, samples := readPNM(t, buf.Bytes())
+			if magic != test.magic || width != 5 || height != 3 || maxval != test.maxval {
+				t.Fatalf("header %s %dx%d %d, want %s 5x3 %d", magic, width, height, maxval, test.magic, test.maxval)
+			}
+
+			want := []int{}
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 5; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					values := []uint32{r, g, b}
+					if test.magic == "P5" {
+						values = []uint32{uint32(color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)}
+					}
+					for _, v := range values {
+						if test.bits == 8 {
+							v >>= 8
+						}
+						want = append(want, int(v))
+					}
+				}
+			}
+			if fmt.Sprint(samples) != fmt.Sprint(want) {
+				t.Fatalf("samples %v, want %v", samples, want)
+			}
+		})
+	}
+}
+
+func TestHDR(t *testing.T) {
+	img := utils.NewRGBF(image.Rect(0, 0, 3, 2))
+	values := [][3]float32{{0, 0, 0}, {1, 0.5, 0.25}, {12.5, 3, 0.001}, {0.18, 0.18, 0.18}, {1e-40, 0, 0}, {1000, 1, 0}}
+	for i, v := range values {
+		img.SetRGBF(i%3, i/3, v[0], v[1], v[2])
+	}
+
+	var buf bytes.Buffer
+	if err := imageio.Encode(&buf, img, ".hdr", imageio.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 2 +X 3\n"
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("header %q, want %q", data[:len(header)], header)
+	}
+	pixels := data[len(header):]
+	if len(pixels) != 4*len(values) {
+		t.Fatalf("%d bytes of pixels, want %d", len(pixels), 4*len(values))
+	}
+
+	for i, v := range values {
+		p := pixels[4*i : 4*i+4]
+		scale := 0.
+		if p[3] != 0 {
+			scale = math.Ldexp(1, int(p[3])-128-8)
+		}
+		largest := math.Max(float64(v[0]), math.Max(float64(v[1]), float64(v[2])))
+		for k := range v {
+			// the mantissas share the exponent of the largest channel
+			if got := (float64(p[k]) + 0.5) * scale; p[3] != 0 && math.Abs(got-float64(v[k])) > largest/128 {
+				t.Errorf("pixel %d channel %d is %g, want %g", i, k, got, v[k])
+			}
+		}
+	}
+}
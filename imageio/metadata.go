@@ -0,0 +1,245 @@
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// maxCommentLength is the largest payload of a JPEG COM segment
+const maxCommentLength = 0xffff - 2
+
+// encodePNG writes img as PNG and stores every metadata entry in a tEXt chunk,
+// or an iTXt chunk when the value is not plain ASCII.
+func encodePNG(w io.Writer, img image.Image, opts Options) error {
+	if len(opts.Metadata) == 0 {
+		return png.Encode(w, img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	// the IHDR chunk has to come first, text chunks may follow anywhere
+	ihdrEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if _, err := w.Write(data[:ihdrEnd]); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(opts.Metadata) {
+		value := opts.Metadata[key]
+		var err error
+		if isASCII(value) {
+			err = writePNGChunk(w, "tEXt", []byte(key+"\x00"+value))
+		} else {
+			// keyword, no compression, no language and no translated keyword
+			err = writePNGChunk(w, "iTXt", []byte(key+"\x00\x00\x00\x00\x00"+value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[ihdrEnd:])
+	return err
+}
+
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
+
+// encodeJPEG writes img as JPEG with every metadata entry in a COM segment
+// holding "key: value".
+func encodeJPEG(w io.Writer, img image.Image, opts Options) error {
+	quality := opts.Quality
+	if quality == 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	// comments go right after the SOI marker
+	if _, err := w.Write(data[:2]); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(opts.Metadata) {
+		comment := key + ": " + opts.Metadata[key]
+		if len(comment) > maxCommentLength {
+			return errors.New("jpeg comment too long: " + key)
+		}
+		segment := []byte{0xff, 0xfe}
+		segment = binary.BigEndian.AppendUint16(segment, uint16(len(comment)+2))
+		segment = append(segment, comment...)
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[2:])
+	return err
+}
+
+// ReadMetadata returns the text metadata stored in the image at filename by
+// Save, which is supported for PNG, JPEG, TIFF and PNM files.
+func ReadMetadata(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return readPNGMetadata(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return readJPEGMetadata(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")):
+		return readTIFFMetadata(data)
+	case len(data) > 1 && data[0] == 'P' && data[1] >= '1' && data[1] <= '6':
+		return readPNMMetadata(data)
+	}
+	return nil, errors.New("unsupported image format for metadata: " + filename)
+}
+
+func readPNGMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		if pos+12+length > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		chunk := data[pos+8 : pos+8+length]
+		pos += 12 + length
+
+		switch kind {
+		case "tEXt":
+			if key, value, ok := bytes.Cut(chunk, []byte{0}); ok {
+				metadata[string(key)] = string(value)
+			}
+		case "iTXt":
+			key, rest, ok := bytes.Cut(chunk, []byte{0})
+			if !ok || len(rest) < 2 || rest[0] != 0 {
+				// compressed text is never written by encodePNG
+				continue
+			}
+			_, rest, _ = bytes.Cut(rest[2:], []byte{0})
+			_, value, _ := bytes.Cut(rest, []byte{0})
+			metadata[string(key)] = string(value)
+		case "IEND":
+			return metadata, nil
+		}
+	}
+	return metadata, nil
+}
+
+func readJPEGMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xff; {
+		marker := data[pos+1]
+		// start of scan, no more comments before the entropy coded data
+		if marker == 0xda {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if pos+2+length > len(data) {
+			return nil, errors.New("truncated jpeg segment")
+		}
+		if marker == 0xfe {
+			comment := string(data[pos+4 : pos+2+length])
+			if key, value, ok := strings.Cut(comment, ": "); ok {
+				metadata[key] = value
+			}
+		}
+		pos += 2 + length
+	}
+	return metadata, nil
+}
+
+func readTIFFMetadata(data []byte) (map[string]string, error) {
+	if len(data) < 8 {
+		return nil, errors.New("truncated tiff header")
+	}
+	le := binary.LittleEndian
+	ifd := int(le.Uint32(data[4:]))
+	if ifd+2 > len(data) {
+		return nil, errors.New("truncated tiff directory")
+	}
+
+	metadata := map[string]string{}
+	count := int(le.Uint16(data[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(data) {
+			return nil, errors.New("truncated tiff directory")
+		}
+		tag, kind, n := le.Uint16(data[entry:]), le.Uint16(data[entry+2:]), int(le.Uint32(data[entry+4:]))
+		if kind != typeASCII || (tag != tagImageDescription && tag != tagSoftware) {
+			continue
+		}
+		value := data[entry+8 : entry+12]
+		if n > 4 {
+			offset := int(le.Uint32(value))
+			if offset+n > len(data) {
+				return nil, errors.New("truncated tiff value")
+			}
+			value = data[offset : offset+n]
+		}
+		text := strings.TrimRight(string(value), "\x00")
+
+		if tag == tagSoftware {
+			metadata[MetadataSoftware] = text
+			continue
+		}
+		for _, line := range strings.Split(text, "\n") {
+			if key, value, ok := strings.Cut(line, ": "); ok {
+				metadata[key] = value
+			}
+		}
+	}
+	return metadata, nil
+}
+
+func readPNMMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data))
+	// skip the magic number, comments follow until the image size
+	scanner.Scan()
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "# ") {
+			break
+		}
+		if key, value, ok := strings.Cut(line[2:], ": "); ok {
+			metadata[key] = value
+		}
+	}
+	return metadata, scanner.Err()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
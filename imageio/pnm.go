@@ -0,0 +1,63 @@
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+)
+
+// EncodePPM writes img as a binary RGB portable pixmap (P6). Images with more
+// than 8 bits per channel are written with a maxval of 65535.
+func EncodePPM(w io.Writer, img image.Image, opts Options) error {
+	return encodePNM(w, img, opts, "P6", 3)
+}
+
+// EncodePGM writes the luminance of img as a binary portable graymap (P5).
+func EncodePGM(w io.Writer, img image.Image, opts Options) error {
+	return encodePNM(w, img, opts, "P5", 1)
+}
+
+func encodePNM(w io.Writer, img image.Image, opts Options, magic string, channels int) error {
+	bounds := img.Bounds()
+	deep := isDeep(img)
+	maxval := 255
+	if deep {
+		maxval = 65535
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, magic)
+	for _, key := range sortedKeys(opts.Metadata) {
+		// comments end at the line break, so keep every value on one line
+		value := strings.ReplaceAll(opts.Metadata[key], "\n", " ")
+		fmt.Fprintf(bw, "# %s: %s\n", key, value)
+	}
+	fmt.Fprintf(bw, "%d %d\n%d\n", bounds.Dx(), bounds.Dy(), maxval)
+
+	sample := make([]uint32, 3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sample[0], sample[1], sample[2] = r, g, b
+			if channels == 1 {
+				// ITU-R BT.601 luma, as used by color.GrayModel
+				sample[0] = (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+			}
+			for _, v := range sample[:channels] {
+				var err error
+				if deep {
+					_, err = bw.Write([]byte{byte(v >> 8), byte(v)})
+				} else {
+					err = bw.WriteByte(byte(v >> 8))
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,126 @@
+// Package imageio encodes rendered images in every output format supported by
+// the command line tool and the web server.
+package imageio
+
+import (
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AksAman/mandelbrot/utils"
+	"golang.org/x/image/bmp"
+)
+
+// Compression selects how TIFF pixel data is compressed.
+type Compression string
+
+const (
+	CompressionNone    Compression = "none"
+	CompressionLZW     Compression = "lzw"
+	CompressionDeflate Compression = "deflate"
+)
+
+// Options configures encoders. Zero values pick each format's defaults.
+type Options struct {
+	// Quality is the JPEG quality, 1 to 100
+	Quality int
+	// Compression is used by TIFF
+	Compression Compression
+	// Metadata is written by formats that can carry text, in key order
+	Metadata map[string]string
+}
+
+// Encoder writes img to w in a single image format.
+type Encoder func(w io.Writer, img image.Image, opts Options) error
+
+type format struct {
+	contentType string
+	encode      Encoder
+}
+
+var formats = map[string]format{}
+
+func init() {
+	Register(".png", "image/png", encodePNG)
+	Register(".jpg", "image/jpeg", encodeJPEG)
+	Register(".jpeg", "image/jpeg", encodeJPEG)
+	Register(".gif", "image/gif", func(w io.Writer, img image.Image, opts Options) error {
+		return gif.Encode(w, img, &gif.Options{NumColors: 256})
+	})
+	Register(".hdr", "image/vnd.radiance", func(w io.Writer, img image.Image, opts Options) error {
+		return EncodeHDR(w, img)
+	})
+	Register(".tif", "image/tiff", EncodeTIFF)
+	Register(".tiff", "image/tiff", EncodeTIFF)
+	Register(".bmp", "image/bmp", func(w io.Writer, img image.Image, opts Options) error {
+		return bmp.Encode(w, img)
+	})
+	Register(".ppm", "image/x-portable-pixmap", EncodePPM)
+	Register(".pnm", "image/x-portable-anymap", EncodePPM)
+	Register(".pgm", "image/x-portable-graymap", EncodePGM)
+}
+
+// Register adds or replaces the encoder used for files with extension ext.
+func Register(ext, contentType string, encode Encoder) {
+	formats[strings.ToLower(ext)] = format{contentType, encode}
+}
+
+// Formats returns the registered extensions in sorted order.
+func Formats() []string {
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// ContentType returns the MIME type of the format with extension ext, or an
+// empty string if it is not registered.
+func ContentType(ext string) string {
+	return formats[strings.ToLower(ext)].contentType
+}
+
+// Encode writes img to w in the format registered for ext, e.g. ".png".
+func Encode(w io.Writer, img image.Image, ext string, opts Options) error {
+	f, ok := formats[strings.ToLower(ext)]
+	if !ok {
+		return errors.New("Unsupported image format: " + ext)
+	}
+	return f.encode(w, img, opts)
+}
+
+// Save writes img to filename in the format matching its extension.
+func Save(img image.Image, filename string, opts Options) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return Encode(f, img, filepath.Ext(filename), opts)
+}
+
+// isDeep reports whether img carries more than 8 bits per channel
+func isDeep(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16, *utils.RGBF:
+		return true
+	}
+	return false
+}
+
+// sortedKeys returns the keys of metadata in a stable order
+func sortedKeys(metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
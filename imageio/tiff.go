@@ -0,0 +1,241 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strings"
+)
+
+// TIFF tags and field types, see the TIFF 6.0 specification
+const (
+	tagImageWidth                = 256
+	tagImageLength               = 257
+	tagBitsPerSample             = 258
+	tagCompression               = 259
+	tagPhotometricInterpretation = 262
+	tagImageDescription          = 270
+	tagStripOffsets              = 273
+	tagSamplesPerPixel           = 277
+	tagRowsPerStrip              = 278
+	tagStripByteCounts           = 279
+	tagXResolution               = 282
+	tagYResolution               = 283
+	tagPlanarConfiguration       = 284
+	tagResolutionUnit            = 296
+	tagSoftware                  = 305
+
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+// MetadataSoftware is the metadata key written to the TIFF Software tag, every
+// other key ends up in the ImageDescription tag.
+const MetadataSoftware = "Software"
+
+type tiffField struct {
+	tag, kind uint16
+	count     uint32
+	data      []byte
+}
+
+// EncodeTIFF writes img as a single strip RGB TIFF. Images with more than 8
+// bits per channel are written with 16 bits per sample. Compression defaults
+// to LZW.
+func EncodeTIFF(w io.Writer, img image.Image, opts Options) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	bits := 8
+	if isDeep(img) {
+		bits = 16
+	}
+
+	raw := make([]byte, 0, width*height*3*bits/8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if bits == 16 {
+				raw = binary.LittleEndian.AppendUint16(raw, uint16(r))
+				raw = binary.LittleEndian.AppendUint16(raw, uint16(g))
+				raw = binary.LittleEndian.AppendUint16(raw, uint16(b))
+			} else {
+				raw = append(raw, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+	}
+
+	var compression uint32
+	var strip bytes.Buffer
+	switch opts.Compression {
+	case CompressionNone:
+		compression = 1
+		strip.Write(raw)
+	case CompressionLZW, "":
+		compression = 5
+		if err := compressLZW(&strip, raw); err != nil {
+			return err
+		}
+	case CompressionDeflate:
+		compression = 8
+		zw := zlib.NewWriter(&strip)
+		if _, err := zw.Write(raw); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported tiff compression: %v", opts.Compression)
+	}
+
+	short := func(values ...uint16) []byte {
+		b := []byte{}
+		for _, v := range values {
+			b = binary.LittleEndian.AppendUint16(b, v)
+		}
+		return b
+	}
+	long := func(v uint32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, v)
+	}
+	ascii := func(s string) []byte {
+		return append([]byte(s), 0)
+	}
+
+	// the strip follows the 8 byte header, the directory follows the strip
+	const stripOffset = 8
+	bitsPerSample := uint16(bits)
+	fields := []tiffField{
+		{tagImageWidth, typeLong, 1, long(uint32(width))},
+		{tagImageLength, typeLong, 1, long(uint32(height))},
+		{tagBitsPerSample, typeShort, 3, short(bitsPerSample, bitsPerSample, bitsPerSample)},
+		{tagCompression, typeShort, 1, short(uint16(compression))},
+		{tagPhotometricInterpretation, typeShort, 1, short(2)},
+		{tagStripOffsets, typeLong, 1, long(stripOffset)},
+		{tagSamplesPerPixel, typeShort, 1, short(3)},
+		{tagRowsPerStrip, typeLong, 1, long(uint32(height))},
+		{tagStripByteCounts, typeLong, 1, long(uint32(strip.Len()))},
+		{tagXResolution, typeRational, 1, append(long(72), long(1)...)},
+		{tagYResolution, typeRational, 1, append(long(72), long(1)...)},
+		{tagPlanarConfiguration, typeShort, 1, short(1)},
+		{tagResolutionUnit, typeShort, 1, short(2)},
+	}
+
+	description := []string{}
+	for _, key := range sortedKeys(opts.Metadata) {
+		if key == MetadataSoftware {
+			s := ascii(opts.Metadata[key])
+			fields = append(fields, tiffField{tagSoftware, typeASCII, uint32(len(s)), s})
+			continue
+		}
+		description = append(description, key+": "+opts.Metadata[key])
+	}
+	if len(description) > 0 {
+		s := ascii(strings.Join(description, "\n"))
+		fields = append(fields, tiffField{tagImageDescription, typeASCII, uint32(len(s)), s})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].tag < fields[j].tag })
+
+	// word align the directory, values that do not fit in an entry follow it
+	padding := strip.Len() % 2
+	ifdOffset := stripOffset + strip.Len() + padding
+	extraOffset := ifdOffset + 2 + 12*len(fields) + 4
+
+	var out bytes.Buffer
+	out.WriteString("II")
+	out.Write(short(42))
+	out.Write(long(uint32(ifdOffset)))
+	out.Write(strip.Bytes())
+	out.Write(make([]byte, padding))
+
+	var extra bytes.Buffer
+	out.Write(short(uint16(len(fields))))
+	for _, f := range fields {
+		out.Write(short(f.tag, f.kind))
+		out.Write(long(f.count))
+		if len(f.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, f.data)
+			out.Write(value)
+			continue
+		}
+		out.Write(long(uint32(extraOffset + extra.Len())))
+		extra.Write(f.data)
+		if extra.Len()%2 == 1 {
+			extra.WriteByte(0)
+		}
+	}
+	out.Write(long(0))
+	out.Write(extra.Bytes())
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// compressLZW compresses data with the TIFF flavour of LZW: MSB first codes
+// that grow one code earlier than in GIF.
+func compressLZW(w io.Writer, data []byte) error {
+	const (
+		clearCode = 256
+		eoiCode   = 257
+		firstCode = 258
+		maxCode   = 4094
+	)
+
+	var acc uint32
+	var nacc uint
+	var err error
+	width := uint(9)
+	write := func(code int) {
+		acc |= uint32(code) << (32 - width - nacc)
+		nacc += width
+		for nacc >= 8 && err == nil {
+			_, err = w.Write([]byte{byte(acc >> 24)})
+			acc <<= 8
+			nacc -= 8
+		}
+	}
+
+	table := map[int]int{}
+	next := firstCode
+	write(clearCode)
+
+	prefix := -1
+	for _, b := range data {
+		if prefix < 0 {
+			prefix = int(b)
+			continue
+		}
+		key := prefix<<8 | int(b)
+		if code, ok := table[key]; ok {
+			prefix = code
+			continue
+		}
+		write(prefix)
+		table[key] = next
+		next++
+		if next == maxCode {
+			write(clearCode)
+			table = map[int]int{}
+			next = firstCode
+			width = 9
+		} else if next > 1<<width-1 {
+			width++
+		}
+		prefix = int(b)
+	}
+	if prefix >= 0 {
+		write(prefix)
+	}
+	write(eoiCode)
+	if nacc > 0 && err == nil {
+		_, err = w.Write([]byte{byte(acc >> 24)})
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package mandelbrot
+
+import (
+	"math"
+
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// kernel is a separable reconstruction filter with a given support radius in pixels
+type kernel struct {
+	radius float64
+	weight func(x float64) float64
+}
+
+var filters = map[Filter]kernel{
+	FilterBox: {
+		radius: 0.5,
+		weight: func(x float64) float64 { return 1 },
+	},
+	FilterGaussian: {
+		radius: 1,
+		weight: func(x float64) float64 { return math.Exp(-2 * x * x) },
+	},
+	FilterLanczos: {
+		radius: 2,
+		weight: func(x float64) float64 { return sinc(x) * sinc(x/2) },
+	},
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// supersample takes Samples x Samples sub-pixel samples per pixel unit across the
+// filter support around (px, py) and combines them in linear RGB, returned
+// as linear light.
+// Wider filters take proportionally more samples to keep the sample density.
+func (mandel *Mandelbrot) supersample(px, py int, stats *Stats) (float64, float64, float64) {
+	cfg := mandel.Config
+	k := filters[cfg.Filter]
+
+	n := int(math.Ceil(2 * k.radius * float64(cfg.Samples)))
+	step := 2 * k.radius / float64(n)
+
+	var r, g, b, total float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			u, v := 0.5, 0.5
+			if cfg.Sampling == SamplingJitter {
+				u, v = jitter(px, py, i*n+j)
+			}
+			dx := -k.radius + (float64(i)+u)*step
+			dy := -k.radius + (float64(j)+v)*step
+
+			w := k.weight(dx) * k.weight(dy)
+			sr, sg, sb, _ := mandel.color(float64(px)+dx, float64(py)+dy, stats)
+			r += w * utils.SrgbToLinear(sr)
+			g += w * utils.SrgbToLinear(sg)
+			b += w * utils.SrgbToLinear(sb)
+			total += w
+		}
+	}
+
+	// filters with negative lobes may overshoot [0, 1], which only the float
+	// depth keeps
+	return r / total, g / total, b / total
+}
+
+// adaptiveFill renders one sample per pixel, then supersamples only the pixels
+// that lie on the boundary of the set or whose color differs from a neighbor
+// by more than Config.Adaptive in any channel.
+func (mandel *Mandelbrot) adaptiveFill() error {
+	width, height := mandel.Config.Width, mandel.Config.Height
+	escaped := make([]bool, width*height)
+
+	err := mandel.fill(func(px, py int, stats *Stats) {
+		r, g, b, esc := mandel.color(float64(px), float64(py), stats)
+		escaped[py*width+px] = esc
+		mandel.setPixel(px, py, r, g, b)
+	})
+	if err != nil {
+		return err
+	}
+
+	refine := make([]bool, width*height)
+	threshold := mandel.Config.Adaptive * 0xffff
+	differs := func(x0, y0, x1, y1 int) bool {
+		if escaped[y0*width+x0] != escaped[y1*width+x1] {
+			return true
+		}
+		r0, g0, b0, _ := mandel.img.At(x0, y0).RGBA()
+		r1, g1, b1, _ := mandel.img.At(x1, y1).RGBA()
+		return math.Abs(float64(r0)-float64(r1)) > threshold ||
+			math.Abs(float64(g0)-float64(g1)) > threshold ||
+			math.Abs(float64(b0)-float64(b1)) > threshold
+	}
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			if px+1 < width && differs(px, py, px+1, py) {
+				refine[py*width+px], refine[py*width+px+1] = true, true
+			}
+			if py+1 < height && differs(px, py, px, py+1) {
+				refine[py*width+px], refine[(py+1)*width+px] = true, true
+			}
+		}
+	}
+
+	return mandel.fill(func(px, py int, stats *Stats) {
+		if refine[py*width+px] {
+			r, g, b := mandel.supersample(px, py, stats)
+			mandel.setLinearPixel(px, py, r, g, b)
+		}
+	})
+}
+
+// jitter returns a deterministic pseudo random offset in [0, 1) x [0, 1) for
+// the given sample of a pixel, so renders are reproducible across modes.
+func jitter(px, py, sample int) (float64, float64) {
+	h := splitmix(uint64(px)<<40 ^ uint64(py)<<20 ^ uint64(sample))
+	return float64(h>>40) / (1 << 24), float64(h&0xffffff) / (1 << 24)
+}
+
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,59 @@
+package mandelbrot_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// BenchmarkCreate renders the same views in every mode, sizes and worker
+// counts, reporting pixels per second next to the usual time and allocations:
+//
+//	go test ./mandelbrot -run '^$' -bench Create
+func BenchmarkCreate(b *testing.B) {
+	views := []struct {
+		name   string
+		config mandelbrot.Config
+	}{
+		{"full", mandelbrot.Config{Smooth: true}},
+		{"seahorse", mandelbrot.Config{Smooth: true, Zoom: 1000, OffsetX: 0.7435, OffsetY: -0.1315}},
+	}
+	modes := []mandelbrot.Mode{mandelbrot.Sequential, mandelbrot.Pixel, mandelbrot.Row, mandelbrot.Parallel}
+
+	for _, view := range views {
+		for _, size := range []int{128, 256} {
+			for _, mode := range modes {
+				workers := []int{1}
+				if mode == mandelbrot.Parallel {
+					// powers of two up to four workers per CPU
+					workers = workers[:0]
+					for w := 1; w <= 4*runtime.GOMAXPROCS(0); w *= 2 {
+						workers = append(workers, w)
+					}
+				}
+				for _, w := range workers {
+					cfg := view.config
+					cfg.Width, cfg.Height = size, size
+					cfg.Mode = mode
+					cfg.Workers = w
+
+					name := fmt.Sprintf("%s/%dx%d/%s", view.name, size, size, mode)
+					if mode == mandelbrot.Parallel {
+						name += fmt.Sprintf("-%d", w)
+					}
+					b.Run(name, func(b *testing.B) {
+						b.ReportAllocs()
+						for i := 0; i < b.N; i++ {
+							if _, err := mandelbrot.Create(cfg); err != nil {
+								b.Fatal(err)
+							}
+						}
+						b.ReportMetric(float64(size*size)*float64(b.N)/b.Elapsed().Seconds(), "pixels/s")
+					})
+				}
+			}
+		}
+	}
+}
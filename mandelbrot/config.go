@@ -1,18 +1,20 @@
 package mandelbrot
 
+import "image"
+
 type SetScale struct {
-	min float64
-	max float64
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
 }
 
 var DefaultXScale = SetScale{
-	min: -1,
-	max: 1,
+	Min: -1,
+	Max: 1,
 }
 
 var DefaultYScale = SetScale{
-	min: -1,
-	max: 1,
+	Min: -1,
+	Max: 1,
 }
 
 type Mode string
@@ -24,20 +26,84 @@ const (
 	Parallel   Mode = "workers"
 )
 
+// Interior selects how points that never escape are colored.
+type Interior string
+
+const (
+	// InteriorWhite leaves points inside the set white, as points that never
+	// escape have a stability of 1
+	InteriorWhite      Interior = "white"
+	InteriorBlack      Interior = "black"
+	InteriorModulus    Interior = "modulus"
+	InteriorPeriod     Interior = "period"
+	InteriorMultiplier Interior = "multiplier"
+	InteriorDistance   Interior = "distance"
+)
+
+// Sampling selects where sub-pixel samples are placed when supersampling.
+type Sampling string
+
+const (
+	// SamplingGrid places samples at the centers of an NxN grid
+	SamplingGrid Sampling = "grid"
+	// SamplingJitter places one random sample inside every cell of the NxN grid
+	SamplingJitter Sampling = "jitter"
+)
+
+// Filter is the reconstruction kernel used to combine sub-pixel samples.
+type Filter string
+
+const (
+	// FilterBox averages samples inside the pixel
+	FilterBox Filter = "box"
+	// FilterGaussian weights samples within one pixel of the center
+	FilterGaussian Filter = "gaussian"
+	// FilterLanczos weights samples within two pixels of the center with a windowed sinc
+	FilterLanczos Filter = "lanczos"
+)
+
+// Depth is the precision of the stored pixels.
+type Depth int
+
+const (
+	Depth8  Depth = 8
+	Depth16 Depth = 16
+	// DepthFloat stores linear light float32 channels for .hdr output. Colors
+	// are not quantized or clamped, but palettes stay within [0, 1], so only
+	// filters overshooting at edges exceed 1: it avoids banding rather than
+	// extending the range.
+	DepthFloat Depth = 32
+)
+
+// Config describes a render. Its JSON fields are lowerCamel, as in config
+// files, metadata and API requests.
 type Config struct {
-	Width, Height int
-	Threshold     float64
-	MaxIterations int
-	XScale        *SetScale
-	YScale        *SetScale
-	Workers       int
-	Scale         int
-	Mode          Mode
-	Zoom          float64
-	Smooth        bool
-	OffsetX       float64
-	OffsetY       float64
-	HueOffset     float64
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	Threshold     float64   `json:"threshold"`
+	MaxIterations int       `json:"maxIterations"`
+	XScale        *SetScale `json:"xScale"`
+	YScale        *SetScale `json:"yScale"`
+	Workers       int       `json:"workers"`
+	Scale         int       `json:"scale"`
+	Mode          Mode      `json:"mode"`
+	Zoom          float64   `json:"zoom"`
+	Smooth        bool      `json:"smooth"`
+	OffsetX       float64   `json:"offsetX"`
+	OffsetY       float64   `json:"offsetY"`
+	HueOffset     float64   `json:"hueOffset"`
+	Palette       Palette   `json:"palette"`
+	// Colors are the stops of a custom gradient as #rrggbb, used instead of
+	// Palette when set
+	Colors   []string `json:"colors,omitempty"`
+	Interior Interior `json:"interior"`
+	Samples  int      `json:"samples"`
+	Sampling Sampling `json:"sampling"`
+	Filter   Filter   `json:"filter"`
+	// Adaptive limits supersampling to pixels on edges, where neighbors differ
+	// by more than this fraction of a color channel. 0 supersamples every pixel.
+	Adaptive float64 `json:"adaptive"`
+	Depth    Depth   `json:"depth"`
 }
 
 var DefaultConfig = Config{
@@ -55,6 +121,37 @@ var DefaultConfig = Config{
 	OffsetX:       0,
 	OffsetY:       0,
 	HueOffset:     0,
+	Palette:       PaletteHSV,
+	Interior:      InteriorWhite,
+	Samples:       1,
+	Sampling:      SamplingGrid,
+	Filter:        FilterBox,
+	Depth:         Depth8,
+}
+
+// Region returns the config that renders only the pixels in r of the image
+// described by cfg, as an image the size of r, matching the full image up to
+// floating point rounding. The view is narrowed through XScale and YScale, so
+// zoom and offsets stay the same.
+func (cfg Config) Region(r image.Rectangle) Config {
+	cfg = configDefault(cfg)
+	x, y := *cfg.XScale, *cfg.YScale
+	cfg.XScale = &SetScale{
+		Min: mapRange(float64(r.Min.X), 0, float64(cfg.Width), x.Min, x.Max),
+		Max: mapRange(float64(r.Max.X), 0, float64(cfg.Width), x.Min, x.Max),
+	}
+	cfg.YScale = &SetScale{
+		Min: mapRange(float64(r.Min.Y), 0, float64(cfg.Height), y.Min, y.Max),
+		Max: mapRange(float64(r.Max.Y), 0, float64(cfg.Height), y.Min, y.Max),
+	}
+	cfg.Width, cfg.Height = r.Dx(), r.Dy()
+	return cfg
+}
+
+// Defaults returns cfg as it will be rendered, with unset fields taken from
+// DefaultConfig and Scale applied to the size.
+func Defaults(cfg Config) Config {
+	return configDefault(cfg)
 }
 
 func configDefault(config ...Config) Config {
@@ -98,6 +195,30 @@ func configDefault(config ...Config) Config {
 		cfg.Mode = DefaultConfig.Mode
 	}
 
+	if cfg.Palette == "" {
+		cfg.Palette = DefaultConfig.Palette
+	}
+
+	if cfg.Interior == "" {
+		cfg.Interior = DefaultConfig.Interior
+	}
+
+	if cfg.Samples == 0 {
+		cfg.Samples = DefaultConfig.Samples
+	}
+
+	if cfg.Sampling == "" {
+		cfg.Sampling = DefaultConfig.Sampling
+	}
+
+	if cfg.Filter == "" {
+		cfg.Filter = DefaultConfig.Filter
+	}
+
+	if cfg.Depth == 0 {
+		cfg.Depth = DefaultConfig.Depth
+	}
+
 	if cfg.Scale == 0 {
 		cfg.Scale = 1
 	}
@@ -108,5 +229,7 @@ func configDefault(config ...Config) Config {
 
 	cfg.Width *= cfg.Scale
 	cfg.Height *= cfg.Scale
+	// the scale is applied now, so the result can be passed through again
+	cfg.Scale = 1
 	return cfg
 }
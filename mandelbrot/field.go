@@ -0,0 +1,65 @@
+package mandelbrot
+
+import (
+	"image"
+)
+
+// Field stores the color of every pixel before Config.HueOffset is applied,
+// so the set can be recolored with any hue offset without iterating again,
+// e.g. for color cycling animations.
+//
+// Fields sample each pixel once, supersampling options are ignored.
+type Field struct {
+	Config Config
+	// h, s, v triples in row order
+	hsv     []float64
+	escaped []bool
+}
+
+// NewField iterates every pixel of the view described by config.
+func NewField(config ...Config) (*Field, error) {
+	if err := validate(config...); err != nil {
+		return nil, err
+	}
+	mandel := initMandelbrot(config...)
+	mandel.Config.Samples = 1
+
+	field := &Field{
+		Config:  mandel.Config,
+		hsv:     make([]float64, 3*mandel.Config.Width*mandel.Config.Height),
+		escaped: make([]bool, mandel.Config.Width*mandel.Config.Height),
+	}
+	err := mandel.fill(func(px, py int, stats *Stats) {
+		h, s, v, escaped := mandel.shade(float64(px), float64(py), stats)
+		i := py*mandel.Config.Width + px
+		field.hsv[3*i], field.hsv[3*i+1], field.hsv[3*i+2] = h, s, v
+		field.escaped[i] = escaped
+	})
+	if err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// Escaped reports whether the point at pixel (px, py) escaped, i.e. lies
+// outside the set.
+func (field *Field) Escaped(px, py int) bool {
+	return field.escaped[py*field.Config.Width+px]
+}
+
+// Image colors the field with hueOffset. The result is the same as rendering
+// the field's config with that HueOffset.
+func (field *Field) Image(hueOffset float64) image.Image {
+	cfg := field.Config
+	cfg.HueOffset = hueOffset
+	mandel := initMandelbrot(cfg)
+
+	for py := 0; py < cfg.Height; py++ {
+		for px := 0; px < cfg.Width; px++ {
+			i := 3 * (py*cfg.Width + px)
+			r, g, b := mandel.hsv(field.hsv[i]+hueOffset, field.hsv[i+1], field.hsv[i+2])
+			mandel.setPixel(px, py, r, g, b)
+		}
+	}
+	return mandel.Image()
+}
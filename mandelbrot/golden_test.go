@@ -0,0 +1,196 @@
+package mandelbrot_test
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+var update = flag.Bool("update", false, "Write the golden images from the current renderer")
+
+// tolerance is the largest difference of a channel from the golden image, in
+// 8 bit steps, that still passes. It absorbs floating point differences
+// between platforms, such as fused multiply-add.
+const tolerance = 3
+
+var modes = []mandelbrot.Mode{mandelbrot.Sequential, mandelbrot.Pixel, mandelbrot.Row, mandelbrot.Parallel}
+
+// goldenViews are small renders covering the coloring options, compared
+// against testdata/golden/<name>.png
+var goldenViews = []struct {
+	name   string
+	config mandelbrot.Config
+}{
+	{"default", mandelbrot.Config{}},
+	{"smooth", mandelbrot.Config{Smooth: true}},
+	{"seahorse", mandelbrot.Config{Smooth: true, Zoom: 200, OffsetX: 0.7435, OffsetY: -0.1315, HueOffset: 200}},
+	{"threshold", mandelbrot.Config{Smooth: true, Threshold: 1000, MaxIterations: 120, Zoom: 20, OffsetX: 0.25, OffsetY: 0.8, HueOffset: 120}},
+	{"interior-modulus", mandelbrot.Config{Smooth: true, Interior: mandelbrot.InteriorModulus}},
+	{"interior-period", mandelbrot.Config{Smooth: true, Interior: mandelbrot.InteriorPeriod}},
+	{"interior-multiplier", mandelbrot.Config{Smooth: true, Interior: mandelbrot.InteriorMultiplier}},
+	{"interior-distance", mandelbrot.Config{Smooth: true, Interior: mandelbrot.InteriorDistance, HueOffset: 90}},
+	{"aa-grid-box", mandelbrot.Config{Smooth: true, Samples: 2}},
+	{"aa-jitter-gaussian", mandelbrot.Config{Smooth: true, Samples: 2, Sampling: mandelbrot.SamplingJitter, Filter: mandelbrot.FilterGaussian}},
+	{"aa-lanczos-adaptive", mandelbrot.Config{Smooth: true, Samples: 3, Filter: mandelbrot.FilterLanczos, Adaptive: 0.05}},
+	{"depth16", mandelbrot.Config{Smooth: true, Depth: mandelbrot.Depth16, Interior: mandelbrot.InteriorDistance}},
+	{"palette-fire", mandelbrot.Config{Smooth: true, Palette: mandelbrot.PaletteFire, Interior: mandelbrot.InteriorDistance}},
+	{"palette-ocean-aa", mandelbrot.Config{Smooth: true, Palette: mandelbrot.PaletteOcean, Samples: 2, HueOffset: 90}},
+	{"depth-float", mandelbrot.Config{Smooth: true, Depth: mandelbrot.DepthFloat, HueOffset: 300}},
+}
+
+func render(t *testing.T, cfg mandelbrot.Config) image.Image {
+	t.Helper()
+	if cfg.Width == 0 {
+		cfg.Width, cfg.Height = 64, 48
+	}
+	img, err := mandelbrot.Create(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img.(*mandelbrot.Mandelbrot).Image()
+}
+
+func TestGolden(t *testing.T) {
+	for _, view := range goldenViews {
+		view := view
+		t.Run(view.name, func(t *testing.T) {
+			img := render(t, view.config)
+			filename := filepath.Join("testdata", "golden", view.name+".png")
+
+			if *update {
+				var buf bytes.Buffer
+				if err := png.Encode(&buf, img); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			f, err := os.Open(filename)
+			if err != nil {
+				t.Fatalf("%v, run go test -update to create it", err)
+			}
+			defer f.Close()
+			golden, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if img.Bounds() != golden.Bounds() {
+				t.Fatalf("bounds %v, golden %v", img.Bounds(), golden.Bounds())
+			}
+			differing, worst := 0, uint32(0)
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					r1, g1, b1, _ := img.At(x, y).RGBA()
+					r2, g2, b2, _ := golden.At(x, y).RGBA()
+					d := maxDiff(r1, r2, g1, g2, b1, b2) / 257
+					if d > tolerance {
+						differing++
+					}
+					if d > worst {
+						worst = d
+					}
+				}
+			}
+			if differing > 0 {
+				actual := filepath.Join(t.TempDir(), view.name+".png")
+				if f, err := os.Create(actual); err == nil {
+					png.Encode(f, img)
+					f.Close()
+				}
+				t.Errorf("%d pixels differ by more than %d, up to %d, from %s, the render is in %s", differing, tolerance, worst, filename, actual)
+			}
+		})
+	}
+}
+
+// TestModesIdentical checks that every fill mode stores exactly the same pixels.
+func TestModesIdentical(t *testing.T) {
+	for _, view := range goldenViews {
+		view := view
+		t.Run(view.name, func(t *testing.T) {
+			var reference image.Image
+			for _, mode := range modes {
+				cfg := view.config
+				cfg.Mode = mode
+				img := render(t, cfg)
+				if reference == nil {
+					reference = img
+					continue
+				}
+				if !bytes.Equal(pixels(img), pixels(reference)) {
+					t.Errorf("mode %s differs from mode %s", mode, modes[0])
+				}
+			}
+		})
+	}
+}
+
+// pixels returns the raw buffer of the images Mandelbrot.Image returns
+func pixels(img image.Image) []byte {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return img.Pix
+	case *image.RGBA64:
+		return img.Pix
+	}
+	// float images compare through their 16 bit colors
+	b := img.Bounds()
+	buf := image.NewRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			buf.Set(x, y, img.At(x, y))
+		}
+	}
+	return buf.Pix
+}
+
+func maxDiff(values ...uint32) uint32 {
+	worst := uint32(0)
+	for i := 0; i < len(values); i += 2 {
+		d := values[i] - values[i+1]
+		if values[i] < values[i+1] {
+			d = values[i+1] - values[i]
+		}
+		if d > worst {
+			worst = d
+		}
+	}
+	return worst
+}
+
+// TestDepthFloatUnclamped checks that the float depth keeps the overshoot of
+// filters with negative lobes, which the other depths clamp.
+func TestDepthFloatUnclamped(t *testing.T) {
+	img := render(t, mandelbrot.Config{Smooth: true, Samples: 2, Filter: mandelbrot.FilterLanczos, Depth: mandelbrot.DepthFloat})
+	rgbf, ok := img.(*utils.RGBF)
+	if !ok {
+		t.Fatalf("got %T, want *utils.RGBF", img)
+	}
+	above := 0
+	for _, v := range rgbf.Pix {
+		if v < 0 {
+			t.Fatalf("channel %g is negative", v)
+		}
+		if v > 1 {
+			above++
+		}
+	}
+	if above == 0 {
+		t.Error("no channel above 1")
+	}
+}
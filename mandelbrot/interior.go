@@ -0,0 +1,123 @@
+package mandelbrot
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+const (
+	// maxPeriod is the longest attracting cycle searched for inside the set
+	maxPeriod = 1024
+	// periodEpsilon is the squared distance at which two orbit points are considered equal
+	periodEpsilon = 1e-12
+	// goldenAngle spreads the hues of consecutive periods apart
+	goldenAngle = 137.50776405003785
+)
+
+// cycle describes the attracting cycle an interior point converges to
+type cycle struct {
+	period     int
+	multiplier complex128
+	distance   float64
+}
+
+// findCycle detects the period of the attracting cycle reached by o and
+// computes its multiplier and the interior distance estimate of o.c.
+// A zero period means no cycle was found.
+func findCycle(o orbit) cycle {
+	c, z0 := o.c, o.z
+
+	period := 0
+	z := z0
+	for p := 1; p <= maxPeriod; p++ {
+		z = z*z + c
+		if abs2(z-z0) < periodEpsilon {
+			period = p
+			break
+		}
+	}
+	if period == 0 {
+		return cycle{}
+	}
+
+	// refine the periodic point with a few Newton steps on f^p(z) - z = 0
+	for i := 0; i < 8; i++ {
+		z, dz := z0, complex(1, 0)
+		for j := 0; j < period; j++ {
+			dz = 2 * z * dz
+			z = z*z + c
+		}
+		if dz == 1 {
+			break
+		}
+		z0 -= (z - z0) / (dz - 1)
+	}
+
+	// derivatives of f^p along the cycle, see
+	// https://en.wikipedia.org/wiki/Plotting_algorithms_for_the_Mandelbrot_set#Interior_distance_estimation
+	z = z0
+	dz, dc := complex(1, 0), complex(0, 0)
+	dzdz, dcdz := complex(0, 0), complex(0, 0)
+	for i := 0; i < period; i++ {
+		dz, dc, dzdz, dcdz = 2*z*dz, 2*z*dc+1, 2*(dz*dz+z*dzdz), 2*(dz*dc+z*dcdz)
+		z = z*z + c
+	}
+
+	distance := 0.
+	if dz != 1 {
+		distance = (1 - abs2(dz)) / cmplx.Abs(dcdz+dzdz*dc/(1-dz))
+	}
+
+	return cycle{
+		period:     period,
+		multiplier: dz,
+		distance:   distance,
+	}
+}
+
+// interiorShade colors a point that never escaped according to Config.Interior,
+// in HSV without the hue offset
+func (mandel *Mandelbrot) interiorShade(o orbit) (float64, float64, float64) {
+	switch mandel.Config.Interior {
+	case InteriorWhite:
+		return 0, 0, 1
+
+	case InteriorModulus:
+		// |z| stays within the radius 2 disc for points inside the set
+		t := utils.ClampFloat(cmplx.Abs(o.z)/2, 0, 1)
+		return t * 360, 0.6, t
+
+	case InteriorPeriod:
+		if cyc := findCycle(o); cyc.period > 0 {
+			return float64(cyc.period) * goldenAngle, 0.7, 0.9
+		}
+
+	case InteriorMultiplier:
+		if cyc := findCycle(o); cyc.period > 0 {
+			angle := cmplx.Phase(cyc.multiplier)/(2*math.Pi)*360 + 180
+			t := utils.ClampFloat(cmplx.Abs(cyc.multiplier), 0, 1)
+			return angle, 0.7, t
+		}
+
+	case InteriorDistance:
+		if cyc := findCycle(o); cyc.period > 0 && cyc.distance > 0 {
+			// reach ~63% brightness 16 pixels away from the boundary
+			t := 1 - math.Exp(-cyc.distance/(16*mandel.pixelSize()))
+			return 240, 0.5, t
+		}
+	}
+
+	return 0, 0, 0
+}
+
+// pixelSize returns the width of a single pixel in the complex plane
+func (mandel *Mandelbrot) pixelSize() float64 {
+	cfg := mandel.Config
+	return (cfg.XScale.Max - cfg.XScale.Min) / float64(cfg.Width) / cfg.Zoom
+}
+
+func abs2(z complex128) float64 {
+	return real(z)*real(z) + imag(z)*imag(z)
+}
@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"log"
+	"image/draw"
 	"math"
+	"math/cmplx"
 	"sync"
 
 	"github.com/AksAman/mandelbrot/utils"
@@ -14,14 +15,53 @@ import (
 // Mandelbrot implements image.Image interface
 type Mandelbrot struct {
 	Config Config
-	img    [][]color.RGBA
+	img    buffer
+	// hsv converts colors, rounding them when stored with 8 bits per channel
+	hsv func(hue, saturation, value float64) (float64, float64, float64)
+	// stats are counted by every goroutine of fill on its own and merged
+	// when it is done
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// Stats counts the work done by a render.
+type Stats struct {
+	// Samples is the number of points iterated, more than the pixels when
+	// supersampling
+	Samples    int64
+	Iterations int64
+	// Interior is the number of samples that did not escape
+	Interior int64
+}
+
+// Stats returns the work done rendering the image.
+func (mandel *Mandelbrot) Stats() Stats {
+	mandel.statsMu.Lock()
+	defer mandel.statsMu.Unlock()
+	return mandel.stats
+}
+
+// addStats merges the counts of a goroutine of fill
+func (mandel *Mandelbrot) addStats(stats *Stats) {
+	mandel.statsMu.Lock()
+	mandel.stats.Samples += stats.Samples
+	mandel.stats.Iterations += stats.Iterations
+	mandel.stats.Interior += stats.Interior
+	mandel.statsMu.Unlock()
+}
+
+// buffer is the pixel storage picked by Config.Depth: *image.RGBA,
+// *image.RGBA64 or *utils.RGBF
+type buffer interface {
+	draw.Image
+	SubImage(r image.Rectangle) image.Image
 }
 
 // interface methods
 
 // ColorModel returns the Image's color model.
 func (mandel *Mandelbrot) ColorModel() color.Model {
-	return color.RGBAModel
+	return mandel.img.ColorModel()
 }
 
 // Bounds returns the domain for which At can return non-zero color.
@@ -32,7 +72,31 @@ func (mandel *Mandelbrot) Bounds() image.Rectangle {
 
 // At returns the color of the pixel at (x, y).
 func (mandel *Mandelbrot) At(x, y int) color.Color {
-	return mandel.img[x][y]
+	return mandel.img.At(x, y)
+}
+
+// Set sets the color of the pixel at (x, y).
+func (mandel *Mandelbrot) Set(x, y int, c color.Color) {
+	mandel.img.Set(x, y, c)
+}
+
+// SubImage returns an image representing the portion of the image visible
+// through r. The returned value shares pixels with the original image.
+func (mandel *Mandelbrot) SubImage(r image.Rectangle) image.Image {
+	return mandel.img.SubImage(r)
+}
+
+// Opaque reports whether the image is fully opaque, which lets encoders skip
+// the alpha channel.
+func (mandel *Mandelbrot) Opaque() bool {
+	return true
+}
+
+// Image returns the underlying pixel buffer, an *image.RGBA, *image.RGBA64 or
+// *utils.RGBF depending on Config.Depth. Encoders have fast paths for the
+// standard library types, so pass this to them instead of the Mandelbrot itself.
+func (mandel *Mandelbrot) Image() image.Image {
+	return mandel.img
 }
 
 func initMandelbrot(config ...Config) *Mandelbrot {
@@ -40,41 +104,53 @@ func initMandelbrot(config ...Config) *Mandelbrot {
 
 	mandel := Mandelbrot{
 		Config: cfg,
-		img:    make([][]color.RGBA, cfg.Width),
+		hsv:    utils.HsvToRgbPrecise,
 	}
 
-	for i := range mandel.img {
-		mandel.img[i] = make([]color.RGBA, cfg.Height)
+	bounds := image.Rect(0, 0, cfg.Width, cfg.Height)
+	switch cfg.Depth {
+	case Depth16:
+		mandel.img = image.NewRGBA64(bounds)
+	case DepthFloat:
+		mandel.img = utils.NewRGBF(bounds)
+	default:
+		mandel.img = image.NewRGBA(bounds)
+		mandel.hsv = utils.HsvToRgbFloat
+	}
+	if stops, ok := gradients[cfg.Palette]; ok {
+		mandel.hsv = gradient(stops)
+	}
+	if stops, err := parseColors(cfg.Colors); err == nil {
+		mandel.hsv = gradient(stops)
 	}
 
 	return &mandel
 }
 
 func Create(config ...Config) (image.Image, error) {
+	if err := validate(config...); err != nil {
+		return nil, err
+	}
 	mandel := initMandelbrot(config...)
 
-	// log.Printf("Using mode: %v\n", mandel.Config.Mode)
-	switch mandel.Config.Mode {
-	case Sequential:
-		mandel.sequentialFill()
-	case Pixel:
-		mandel.fillUsingOneGoroutinePerPixel()
-	case Row:
-		mandel.fillUsingOneGoroutinePerRow()
-	case Parallel:
-		mandel.fillUsingWorkers()
-	default:
-		return nil, fmt.Errorf("invalid mode: %v", mandel.Config.Mode)
+	var err error
+	if mandel.Config.Samples > 1 && mandel.Config.Adaptive > 0 {
+		err = mandel.adaptiveFill()
+	} else {
+		err = mandel.fill(mandel.fillPixel)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// for i, row := range mandel.img {
-	// 	for j := range row {
+	// for j := 0; j < mandel.Config.Height; j++ {
+	// 	for i := 0; i < mandel.Config.Width; i++ {
 	// 		if i == mandel.Config.Width/2 || j == mandel.Config.Height/2 {
-	// 			mandel.img[i][j] = color.RGBA{255, 0, 0, 255}
+	// 			mandel.img.SetRGBA(i, j, color.RGBA{255, 0, 0, 255})
 	// 		}
 
 	// 		if i == int(float64(mandel.Config.Width)*mandel.Config.OffsetX) || j == int(math.Abs(float64(mandel.Config.Height)*mandel.Config.OffsetY)) {
-	// 			mandel.img[i][j] = color.RGBA{0, 0, 255, 255}
+	// 			mandel.img.SetRGBA(i, j, color.RGBA{0, 0, 255, 255})
 	// 		}
 
 	// 	}
@@ -83,55 +159,89 @@ func Create(config ...Config) (image.Image, error) {
 	return mandel, nil
 }
 
-// --scale 1 --threshold 32 --iter 1000  0.57s user 0.17s system 112% cpu 0.660 total
+// validate checks the config passed to Create or NewField before any
+// buffer is allocated, since an invalid size could exhaust memory
+func validate(config ...Config) error {
+	if len(config) < 1 {
+		return nil
+	}
+	if err := config[0].Validate(); err != nil {
+		return err
+	}
+	return config[0].ValidateSize()
+}
+
+// fill calls fn once for every pixel, scheduling the calls according to
+// Config.Mode. Every goroutine passes fn its own Stats to count in, so the
+// counts are not contended.
+func (mandel *Mandelbrot) fill(fn func(px, py int, stats *Stats)) error {
+	// log.Printf("Using mode: %v\n", mandel.Config.Mode)
+	switch mandel.Config.Mode {
+	case Sequential:
+		mandel.sequentialFill(fn)
+	case Pixel:
+		mandel.fillUsingOneGoroutinePerPixel(fn)
+	case Row:
+		mandel.fillUsingOneGoroutinePerRow(fn)
+	case Parallel:
+		mandel.fillUsingWorkers(fn)
+	default:
+		return fmt.Errorf("invalid mode: %v", mandel.Config.Mode)
+	}
+	return nil
+}
+
 // sequentialFill fills the image sequentially
-func (mandel *Mandelbrot) sequentialFill() {
-	for i, row := range mandel.img {
-		for j := range row {
-			mandel.fillPixel(i, j)
+func (mandel *Mandelbrot) sequentialFill(fn func(px, py int, stats *Stats)) {
+	var stats Stats
+	for j := 0; j < mandel.Config.Height; j++ {
+		for i := 0; i < mandel.Config.Width; i++ {
+			fn(i, j, &stats)
 		}
 	}
+	mandel.addStats(&stats)
 }
 
-// --scale 1 --threshold 32 --iter 1000  1.12s user 0.27s system 247% cpu 0.564 total
 // fillUsingOneGoroutinePerPixel one goroutine per pixel
-func (mandel *Mandelbrot) fillUsingOneGoroutinePerPixel() {
+func (mandel *Mandelbrot) fillUsingOneGoroutinePerPixel(fn func(px, py int, stats *Stats)) {
 	wg := &sync.WaitGroup{}
 	wg.Add(mandel.Config.Width * mandel.Config.Height)
 	// log.Printf("using %v goroutines\n", mandel.Config.Width*mandel.Config.Height)
-	for i, row := range mandel.img {
-		for j := range row {
+	for j := 0; j < mandel.Config.Height; j++ {
+		for i := 0; i < mandel.Config.Width; i++ {
 			go func(i, j int) {
 				defer wg.Done()
-				mandel.fillPixel(i, j)
+				var stats Stats
+				fn(i, j, &stats)
+				mandel.addStats(&stats)
 			}(i, j)
 		}
 	}
 	wg.Wait()
 }
 
-// --scale 1 --threshold 32 --iter 1000  0.76s user 0.15s system 235% cpu 0.384 total
 // fillUsingOneGoroutinePerRow creates one goroutine for every row
-func (mandel *Mandelbrot) fillUsingOneGoroutinePerRow() {
+func (mandel *Mandelbrot) fillUsingOneGoroutinePerRow(fn func(px, py int, stats *Stats)) {
 	wg := &sync.WaitGroup{}
-	wg.Add(mandel.Config.Width)
-	for i := range mandel.img {
-		go func(i int) {
+	wg.Add(mandel.Config.Height)
+	for j := 0; j < mandel.Config.Height; j++ {
+		go func(j int) {
 			defer wg.Done()
-			for j := range mandel.img[i] {
-				mandel.fillPixel(i, j)
+			var stats Stats
+			for i := 0; i < mandel.Config.Width; i++ {
+				fn(i, j, &stats)
 			}
-		}(i)
+			mandel.addStats(&stats)
+		}(j)
 	}
 	wg.Wait()
 }
 
-// --scale 1 --threshold 32 --iter 1000  1.30s user 0.23s system 179% cpu 0.856 total
 // fillUsingWorkers uses fixed user defined count of goroutines to fill image
-func (mandel *Mandelbrot) fillUsingWorkers() {
+func (mandel *Mandelbrot) fillUsingWorkers(fn func(px, py int, stats *Stats)) {
 	workers := mandel.Config.Workers
 
-	log.Printf("using %v workers\n", workers)
+	// log.Printf("using %v workers\n", workers)
 
 	type workerJob struct {
 		i, j int
@@ -145,16 +255,17 @@ func (mandel *Mandelbrot) fillUsingWorkers() {
 	for i := 0; i < workers; i++ {
 		go func() {
 			defer wg.Done()
+			var stats Stats
 			for job := range workerChan {
-				mandel.fillPixel(job.i, job.j)
+				fn(job.i, job.j, &stats)
 			}
+			mandel.addStats(&stats)
 		}()
 	}
 
 	// create jobs and send on channel
-	for i, row := range mandel.img {
-		for j := range row {
-			mandel.fillPixel(i, j)
+	for j := 0; j < mandel.Config.Height; j++ {
+		for i := 0; i < mandel.Config.Width; i++ {
 			workerChan <- workerJob{i, j}
 		}
 	}
@@ -163,11 +274,20 @@ func (mandel *Mandelbrot) fillUsingWorkers() {
 	wg.Wait()
 }
 
-func (mandel *Mandelbrot) escapeCount(px, py int, smooth bool) float64 {
+// orbit holds the state reached after iterating a single point
+type orbit struct {
+	c, z       complex128
+	iterations int
+	escaped    bool
+}
+
+// iterate runs the escape time loop for the point at pixel coordinates (px, py),
+// which do not have to be whole pixels
+func (mandel *Mandelbrot) iterate(px, py float64) orbit {
 	cfg := mandel.Config
 
-	x0 := (mapRange(float64(px), 0, float64(cfg.Width), cfg.XScale.min, cfg.XScale.max) / cfg.Zoom) - cfg.OffsetX
-	y0 := (mapRange(float64(py), 0, float64(cfg.Height), cfg.YScale.min, cfg.YScale.max) / cfg.Zoom) - cfg.OffsetY
+	x0 := (mapRange(px, 0, float64(cfg.Width), cfg.XScale.Min, cfg.XScale.Max) / cfg.Zoom) - cfg.OffsetX
+	y0 := (mapRange(py, 0, float64(cfg.Height), cfg.YScale.Min, cfg.YScale.Max) / cfg.Zoom) - cfg.OffsetY
 	// fmt.Printf("(x0, y0): (%v, %v)\n", x0, y0)
 	x, y := 0., 0.
 	x2, y2 := 0., 0.
@@ -180,14 +300,23 @@ func (mandel *Mandelbrot) escapeCount(px, py int, smooth bool) float64 {
 		y2 = y * y
 	}
 
+	return orbit{
+		c:          complex(x0, y0),
+		z:          complex(x, y),
+		iterations: iterations,
+		escaped:    x2+y2 > cfg.Threshold,
+	}
+}
+
+func (mandel *Mandelbrot) escapeCount(o orbit, smooth bool) float64 {
 	if smooth {
-		return float64(iterations) + 1 - math.Log(math.Log(math.Sqrt(x2+y2)))/math.Log(2)
+		return float64(o.iterations) + 1 - math.Log(math.Log(cmplx.Abs(o.z)))/math.Log(2)
 	}
-	return float64(iterations)
+	return float64(o.iterations)
 }
 
-func (mandel *Mandelbrot) stability(px, py int, smooth bool, clamp bool) float64 {
-	value := mandel.escapeCount(px, py, smooth) / float64(mandel.Config.MaxIterations)
+func (mandel *Mandelbrot) stability(o orbit, smooth bool, clamp bool) float64 {
+	value := mandel.escapeCount(o, smooth) / float64(mandel.Config.MaxIterations)
 
 	if clamp {
 		value = utils.ClampFloat(value, 0, 1)
@@ -195,26 +324,70 @@ func (mandel *Mandelbrot) stability(px, py int, smooth bool, clamp bool) float64
 	return value
 }
 
-func (mandel *Mandelbrot) fillPixel(px, py int) {
-	stability := mandel.stability(px, py, mandel.Config.Smooth, true)
+// color returns the color of the point at pixel coordinates (px, py) with channels
+// in [0, 1], and whether the point escaped
+func (mandel *Mandelbrot) color(px, py float64, stats *Stats) (float64, float64, float64, bool) {
+	h, s, v, escaped := mandel.shade(px, py, stats)
+	r, g, b := mandel.hsv(h+mandel.Config.HueOffset, s, v)
+	return r, g, b, escaped
+}
+
+// shade returns the color of the point at pixel coordinates (px, py) in HSV
+// before Config.HueOffset is added to the hue, and whether the point escaped.
+// The point is counted in stats.
+func (mandel *Mandelbrot) shade(px, py float64, stats *Stats) (float64, float64, float64, bool) {
+	o := mandel.iterate(px, py)
+	stats.Samples++
+	stats.Iterations += int64(o.iterations)
+	if !o.escaped {
+		stats.Interior++
+		h, s, v := mandel.interiorShade(o)
+		return h, s, v, false
+	}
+
+	stability := mandel.stability(o, mandel.Config.Smooth, true)
 	instability := 1. - stability
 
-	var r, g, b uint8
 	if instability == 1 {
-	} else {
-		r, g, b = utils.HsvToRgb((instability)*360+mandel.Config.HueOffset, instability, stability)
+		return 0, 0, 0, true
 	}
+	return instability * 360, instability, stability, true
+}
 
-	// fmt.Printf("r, g, b: %v, %v, %v\n", r, g, b)
+func (mandel *Mandelbrot) fillPixel(px, py int, stats *Stats) {
+	if mandel.Config.Samples > 1 {
+		r, g, b := mandel.supersample(px, py, stats)
+		mandel.setLinearPixel(px, py, r, g, b)
+		return
+	}
+	r, g, b, _ := mandel.color(float64(px), float64(py), stats)
 
-	addRGBColor(&mandel.img[px][py], r, g, b)
+	// fmt.Printf("r, g, b: %v, %v, %v\n", r, g, b)
 
+	mandel.setPixel(px, py, r, g, b)
 }
 
-func addColor(c *color.RGBA, baseColor uint8) {
-	c.R, c.G, c.B, c.A = baseColor, baseColor, baseColor, 255
+// setLinearPixel stores a linear light color. The float depth keeps values
+// above 1, the others clamp them to [0, 1] and encode them as sRGB.
+func (mandel *Mandelbrot) setLinearPixel(px, py int, r, g, b float64) {
+	if img, ok := mandel.img.(*utils.RGBF); ok {
+		img.SetRGBF(px, py, float32(math.Max(r, 0)), float32(math.Max(g, 0)), float32(math.Max(b, 0)))
+		return
+	}
+	encode := func(c float64) float64 {
+		return utils.LinearToSrgb(utils.ClampFloat(c, 0, 1))
+	}
+	mandel.setPixel(px, py, encode(r), encode(g), encode(b))
 }
 
-func addRGBColor(c *color.RGBA, r uint8, g uint8, b uint8) {
-	c.R, c.G, c.B, c.A = r, g, b, 255
+// setPixel stores a gamma encoded color with channels in [0, 1]
+func (mandel *Mandelbrot) setPixel(px, py int, r, g, b float64) {
+	switch img := mandel.img.(type) {
+	case *image.RGBA:
+		img.SetRGBA(px, py, color.RGBA{uint8(r * 255.0), uint8(g * 255.0), uint8(b * 255.0), 255})
+	case *image.RGBA64:
+		img.SetRGBA64(px, py, color.RGBA64{uint16(r * 0xffff), uint16(g * 0xffff), uint16(b * 0xffff), 0xffff})
+	case *utils.RGBF:
+		img.SetRGBF(px, py, float32(utils.SrgbToLinear(r)), float32(utils.SrgbToLinear(g)), float32(utils.SrgbToLinear(b)))
+	}
 }
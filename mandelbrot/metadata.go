@@ -0,0 +1,41 @@
+package mandelbrot
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version identifies the renderer that produced an image and is stored next
+// to the render parameters in image metadata.
+const Version = "0.2.0"
+
+// MetadataKey is the image metadata key the render parameters are stored under.
+const MetadataKey = "mandelbrot"
+
+type metadata struct {
+	Version string `json:"version"`
+	Config  Config `json:"config"`
+}
+
+// EncodeMetadata returns the JSON document describing cfg that is embedded in
+// output images. cfg should be the final config of a render, as found in
+// Mandelbrot.Config, so that Width and Height already include Scale.
+func EncodeMetadata(cfg Config) (string, error) {
+	data, err := json.Marshal(metadata{
+		Version: Version,
+		Config:  cfg,
+	})
+	return string(data), err
+}
+
+// DecodeMetadata parses a document written by EncodeMetadata.
+func DecodeMetadata(document string) (Config, error) {
+	var meta metadata
+	if err := json.Unmarshal([]byte(document), &meta); err != nil {
+		return Config{}, fmt.Errorf("invalid render parameters: %w", err)
+	}
+	if meta.Version == "" {
+		return Config{}, fmt.Errorf("invalid render parameters: missing version")
+	}
+	return meta.Config, nil
+}
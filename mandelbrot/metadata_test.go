@@ -0,0 +1,39 @@
+package mandelbrot_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+func TestMetadata(t *testing.T) {
+	cfg := mandelbrot.Defaults(mandelbrot.Config{Zoom: 1000, OffsetX: 0.7435, MaxIterations: 2000})
+	document, err := mandelbrot.EncodeMetadata(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fields are those of config files and API requests
+	for _, field := range []string{`"maxIterations":2000`, `"offsetX":0.7435`, `"xScale":{"min":-1,"max":1}`} {
+		if !strings.Contains(document, field) {
+			t.Errorf("%s has no %s", document, field)
+		}
+	}
+
+	decoded, err := mandelbrot.DecodeMetadata(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Zoom != cfg.Zoom || decoded.OffsetX != cfg.OffsetX || decoded.MaxIterations != cfg.MaxIterations || *decoded.XScale != *cfg.XScale {
+		t.Errorf("decoded %+v, want %+v", decoded, cfg)
+	}
+
+	// images written before the fields had tags still load
+	decoded, err = mandelbrot.DecodeMetadata(`{"version":"0.2.0","config":{"MaxIterations":2000,"OffsetX":0.7435}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.MaxIterations != 2000 || decoded.OffsetX != 0.7435 {
+		t.Errorf("decoded %+v from PascalCase fields", decoded)
+	}
+}
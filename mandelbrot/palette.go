@@ -0,0 +1,80 @@
+package mandelbrot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Palette selects the colors hues map to.
+type Palette string
+
+const (
+	// PaletteHSV uses the hue as is, going around the color wheel
+	PaletteHSV Palette = "hsv"
+	// PaletteFire goes from black through red and yellow to white
+	PaletteFire Palette = "fire"
+	// PaletteOcean goes from deep blue through cyan to white
+	PaletteOcean Palette = "ocean"
+	// PaletteTwilight goes from purple through orange to pale yellow
+	PaletteTwilight Palette = "twilight"
+	// PaletteGray goes from black to white
+	PaletteGray Palette = "gray"
+)
+
+// gradients are the sRGB stops of the palettes other than PaletteHSV, spread
+// evenly over the hue circle. The last stop blends back into the first, so
+// hue offsets cycle smoothly.
+var gradients = map[Palette][][3]float64{
+	PaletteFire:     {{0, 0, 0}, {0.5, 0, 0}, {1, 0.3, 0}, {1, 0.8, 0.1}, {1, 1, 0.8}, {0.6, 0.1, 0}},
+	PaletteOcean:    {{0, 0.02, 0.15}, {0, 0.2, 0.5}, {0, 0.6, 0.8}, {0.7, 1, 1}, {0.1, 0.4, 0.6}},
+	PaletteTwilight: {{0.15, 0.05, 0.3}, {0.6, 0.2, 0.5}, {1, 0.5, 0.3}, {1, 0.9, 0.6}, {0.4, 0.15, 0.45}},
+	PaletteGray:     {{0, 0, 0}, {1, 1, 1}},
+}
+
+// Palettes lists the valid palettes.
+var Palettes = []Palette{PaletteHSV, PaletteFire, PaletteOcean, PaletteTwilight, PaletteGray}
+
+// maxColors is the largest number of stops of a custom gradient
+const maxColors = 64
+
+// parseColors converts Config.Colors to gradient stops
+func parseColors(colors []string) ([][3]float64, error) {
+	if len(colors) < 2 || len(colors) > maxColors {
+		return nil, fmt.Errorf("must have between 2 and %d colors", maxColors)
+	}
+	stops := make([][3]float64, len(colors))
+	for i, c := range colors {
+		hex := strings.TrimPrefix(c, "#")
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 || len(c) != 7 {
+			return nil, fmt.Errorf("has %q, which is not a #rrggbb color", c)
+		}
+		stops[i] = [3]float64{float64(rgb>>16) / 255, float64(rgb>>8&0xff) / 255, float64(rgb&0xff) / 255}
+	}
+	return stops, nil
+}
+
+// gradient returns a color function like the HSV ones that looks up the hue
+// in stops. Saturation blends the color with white and value darkens it, as
+// in HSV.
+func gradient(stops [][3]float64) func(hue, saturation, value float64) (float64, float64, float64) {
+	return func(hue, saturation, value float64) (float64, float64, float64) {
+		t := math.Mod(hue, 360) / 360
+		if t < 0 {
+			t++
+		}
+		t *= float64(len(stops))
+		i := int(t) % len(stops)
+		from, to := stops[i], stops[(i+1)%len(stops)]
+		f := t - math.Floor(t)
+
+		var c [3]float64
+		for k := range c {
+			mixed := from[k] + (to[k]-from[k])*f
+			c[k] = value * (1 - saturation + saturation*mixed)
+		}
+		return c[0], c[1], c[2]
+	}
+}
@@ -0,0 +1,94 @@
+package mandelbrot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// presetsKey holds the named presets of a config file
+const presetsKey = "presets"
+
+// Override returns cfg with the fields present in the JSON object data
+// replaced, e.g. {"zoom": 1000, "offsetX": 0.7435}. Field names are those of
+// Config and match case-insensitively; unknown fields are an error so typos
+// do not go unnoticed.
+func Override(cfg Config, data []byte) (Config, error) {
+	// decode into copies, the scales may point to the defaults
+	if cfg.XScale != nil {
+		x := *cfg.XScale
+		cfg.XScale = &x
+	}
+	if cfg.YScale != nil {
+		y := *cfg.YScale
+		cfg.YScale = &y
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// LoadConfig applies the config file filename to cfg. The file is a JSON
+// object of Config fields, and may name presets that apply on top of them:
+//
+//	{
+//		"width": 1920, "height": 1080, "maxIterations": 2000,
+//		"presets": {
+//			"seahorse": {"zoom": 1000, "offsetX": 0.7435, "offsetY": -0.1315}
+//		}
+//	}
+//
+// With an empty preset only the top level fields are applied.
+func LoadConfig(filename, preset string, cfg Config) (Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Config{}, fmt.Errorf("invalid config in %s: %w", filename, err)
+	}
+
+	presets := map[string]json.RawMessage{}
+	for key, value := range fields {
+		if strings.EqualFold(key, presetsKey) {
+			if err := json.Unmarshal(value, &presets); err != nil {
+				return Config{}, fmt.Errorf("invalid presets in %s: %w", filename, err)
+			}
+			delete(fields, key)
+		}
+	}
+
+	base, err := json.Marshal(fields)
+	if err != nil {
+		return Config{}, err
+	}
+	if cfg, err = Override(cfg, base); err != nil {
+		return Config{}, fmt.Errorf("invalid config in %s: %w", filename, err)
+	}
+	if preset == "" {
+		return cfg, nil
+	}
+
+	data, ok := presets[preset]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Config{}, fmt.Errorf("no preset %q in %s (presets: %s)", preset, filename, strings.Join(names, ", "))
+	}
+	if cfg, err = Override(cfg, data); err != nil {
+		return Config{}, fmt.Errorf("invalid preset %q in %s: %w", preset, filename, err)
+	}
+	return cfg, nil
+}
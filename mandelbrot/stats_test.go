@@ -0,0 +1,25 @@
+package mandelbrot_test
+
+import (
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+func TestStats(t *testing.T) {
+	var reference mandelbrot.Stats
+	for _, mode := range modes {
+		img, err := mandelbrot.Create(mandelbrot.Config{Width: 64, Height: 48, MaxIterations: 200, Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := img.(*mandelbrot.Mandelbrot).Stats()
+		if stats.Samples != 64*48 || stats.Interior == 0 || stats.Iterations < 200*stats.Interior {
+			t.Errorf("mode %s: got %+v", mode, stats)
+		}
+		if mode != modes[0] && stats != reference {
+			t.Errorf("mode %s: got %+v, mode %s %+v", mode, stats, modes[0], reference)
+		}
+		reference = stats
+	}
+}
@@ -0,0 +1,239 @@
+package mandelbrot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// MaxPixels is the largest image ValidateSize accepts, and so the largest
+// Create allocates. At 8 bits a pixel takes 4 bytes, at 16 bits 8 and as float
+// 12. Images rendered in tiles may be larger, as long as every tile fits.
+var MaxPixels int64 = 1 << 28
+
+// maxSide is the largest width or height, so that sizes fit in an int32
+const maxSide = math.MaxInt32
+
+// MaxSamples is the largest number of sub-pixel samples per axis.
+const MaxSamples = 16
+
+// probeSize is the number of points per axis iterated by Estimate
+const probeSize = 16
+
+// FieldError reports an invalid field of a Config.
+type FieldError struct {
+	// Field is the name of the Config field
+	Field  string
+	Value  any
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s %v %s", e.Field, e.Value, e.Reason)
+}
+
+// ValidationError lists every invalid field of a Config.
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	reasons := make([]string, len(e))
+	for i, err := range e {
+		reasons[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(reasons, "; ")
+}
+
+// Validate checks cfg as it would be rendered, after zero values are replaced
+// by defaults, and returns a ValidationError listing every invalid field. The
+// size is only limited by ValidateSize, which whole images also have to pass.
+func (cfg Config) Validate() error {
+	var errs ValidationError
+	invalid := func(field string, value any, reason string) {
+		errs = append(errs, &FieldError{Field: field, Value: value, Reason: reason})
+	}
+	finite := func(field string, value float64) {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			invalid(field, value, "must be a finite number")
+		}
+	}
+
+	// the size is checked before defaults multiply it with Scale, which could overflow
+	if cfg.Width < 0 {
+		invalid("Width", cfg.Width, "must not be negative")
+	}
+	if cfg.Height < 0 {
+		invalid("Height", cfg.Height, "must not be negative")
+	}
+	if cfg.Scale < 0 {
+		invalid("Scale", cfg.Scale, "must not be negative")
+	}
+	if len(errs) == 0 {
+		if width, height := scaledSize(cfg); width > maxSide || height > maxSide {
+			invalid("Scale", cfg.Scale, fmt.Sprintf("makes the image %.0fx%.0f, more than %d pixels wide or high", width, height, maxSide))
+		}
+	}
+	cfg.Scale = 1
+	cfg = configDefault(cfg)
+
+	if cfg.MaxIterations < 0 {
+		invalid("MaxIterations", cfg.MaxIterations, "must be positive")
+	}
+	if cfg.Workers < 0 {
+		invalid("Workers", cfg.Workers, "must be positive")
+	}
+	if finite("Threshold", cfg.Threshold); cfg.Threshold < 0 {
+		invalid("Threshold", cfg.Threshold, "must be positive")
+	}
+	if finite("Zoom", cfg.Zoom); cfg.Zoom < 0 {
+		invalid("Zoom", cfg.Zoom, "must be positive")
+	}
+	finite("OffsetX", cfg.OffsetX)
+	finite("OffsetY", cfg.OffsetY)
+	finite("HueOffset", cfg.HueOffset)
+
+	for _, scale := range []struct {
+		field string
+		value *SetScale
+	}{{"XScale", cfg.XScale}, {"YScale", cfg.YScale}} {
+		finite(scale.field+".Min", scale.value.Min)
+		finite(scale.field+".Max", scale.value.Max)
+		if scale.value.Min == scale.value.Max {
+			invalid(scale.field, *scale.value, "must not be empty")
+		}
+	}
+
+	switch cfg.Mode {
+	case Sequential, Pixel, Row, Parallel:
+	default:
+		invalid("Mode", cfg.Mode, "is not one of seq, pixel, row, workers")
+	}
+	if _, ok := gradients[cfg.Palette]; !ok && cfg.Palette != PaletteHSV {
+		invalid("Palette", cfg.Palette, "is not one of hsv, fire, ocean, twilight, gray")
+	}
+	if len(cfg.Colors) > 0 {
+		if _, err := parseColors(cfg.Colors); err != nil {
+			invalid("Colors", cfg.Colors, err.Error())
+		}
+	}
+	switch cfg.Interior {
+	case InteriorWhite, InteriorBlack, InteriorModulus, InteriorPeriod, InteriorMultiplier, InteriorDistance:
+	default:
+		invalid("Interior", cfg.Interior, "is not one of white, black, modulus, period, multiplier, distance")
+	}
+	switch cfg.Sampling {
+	case SamplingGrid, SamplingJitter:
+	default:
+		invalid("Sampling", cfg.Sampling, "is not one of grid, jitter")
+	}
+	if _, ok := filters[cfg.Filter]; !ok {
+		invalid("Filter", cfg.Filter, "is not one of box, gaussian, lanczos")
+	}
+	switch cfg.Depth {
+	case Depth8, Depth16, DepthFloat:
+	default:
+		invalid("Depth", cfg.Depth, "is not one of 8, 16, 32")
+	}
+
+	if cfg.Samples < 1 || cfg.Samples > MaxSamples {
+		invalid("Samples", cfg.Samples, fmt.Sprintf("must be between 1 and %d", MaxSamples))
+	}
+	if finite("Adaptive", cfg.Adaptive); cfg.Adaptive < 0 || cfg.Adaptive > 1 {
+		invalid("Adaptive", cfg.Adaptive, "must be between 0 and 1")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateSize returns a ValidationError if the image of cfg, which should be
+// valid, has more than MaxPixels pixels.
+func (cfg Config) ValidateSize() error {
+	width, height := scaledSize(cfg)
+	if width*height <= float64(MaxPixels) {
+		return nil
+	}
+	reason := fmt.Sprintf("makes the image %.0fx%.0f, more than %d pixels", width, height, MaxPixels)
+	if cfg.Scale > 1 {
+		return ValidationError{{Field: "Scale", Value: cfg.Scale, Reason: reason}}
+	}
+	return ValidationError{{Field: "Width", Value: cfg.Width, Reason: reason}}
+}
+
+// scaledSize returns the size of the image of cfg in floats, which unlike
+// configDefault cannot overflow
+func scaledSize(cfg Config) (float64, float64) {
+	scale := float64(utils.Max(cfg.Scale, 1))
+	cfg.Scale = 1
+	cfg = configDefault(cfg)
+	return float64(cfg.Width) * scale, float64(cfg.Height) * scale
+}
+
+// Resources is an estimate of what rendering a config takes.
+type Resources struct {
+	Pixels int64
+	// Bytes is the size of the pixel buffer
+	Bytes int64
+	// Samples is the number of points iterated, more than Pixels when
+	// supersampling. Adaptive supersampling usually iterates fewer.
+	Samples int64
+	// Iterations is the expected total, from the mean iterations of a coarse
+	// grid of points across the view
+	Iterations int64
+	// MaxIterations is the total if no point escaped
+	MaxIterations int64
+	// Goroutines is the number of goroutines the mode starts
+	Goroutines int
+}
+
+// Estimate returns the resources needed to render cfg, which should be valid.
+// It iterates a few hundred points, far fewer than rendering.
+func (cfg Config) Estimate() Resources {
+	cfg = configDefault(cfg)
+
+	res := Resources{Pixels: int64(cfg.Width) * int64(cfg.Height)}
+	switch cfg.Depth {
+	case Depth16:
+		res.Bytes = 8 * res.Pixels
+	case DepthFloat:
+		res.Bytes = 12 * res.Pixels
+	default:
+		res.Bytes = 4 * res.Pixels
+	}
+
+	perPixel := int64(1)
+	if cfg.Samples > 1 {
+		// as in supersample
+		n := int64(math.Ceil(2 * filters[cfg.Filter].radius * float64(cfg.Samples)))
+		perPixel = n * n
+	}
+	res.Samples = res.Pixels * perPixel
+	res.MaxIterations = res.Samples * int64(cfg.MaxIterations)
+
+	// iterating needs no pixel buffer
+	probe := Mandelbrot{Config: cfg}
+	total := 0
+	for j := 0; j < probeSize; j++ {
+		for i := 0; i < probeSize; i++ {
+			px := (float64(i) + 0.5) * float64(cfg.Width) / probeSize
+			py := (float64(j) + 0.5) * float64(cfg.Height) / probeSize
+			total += probe.iterate(px, py).iterations
+		}
+	}
+	res.Iterations = int64(float64(total) / (probeSize * probeSize) * float64(res.Samples))
+
+	switch cfg.Mode {
+	case Pixel:
+		res.Goroutines = int(res.Pixels)
+	case Row:
+		res.Goroutines = cfg.Height
+	case Parallel:
+		res.Goroutines = cfg.Workers
+	default:
+		res.Goroutines = 1
+	}
+	return res
+}
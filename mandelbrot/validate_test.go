@@ -0,0 +1,77 @@
+package mandelbrot_test
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+func TestValidate(t *testing.T) {
+	invalid := []struct {
+		field  string
+		config mandelbrot.Config
+	}{
+		{"Width", mandelbrot.Config{Width: -1}},
+		{"Scale", mandelbrot.Config{Scale: 1 << 30}},
+		{"Workers", mandelbrot.Config{Workers: -2}},
+		{"Zoom", mandelbrot.Config{Zoom: math.NaN()}},
+		{"OffsetX", mandelbrot.Config{OffsetX: math.Inf(1)}},
+		{"XScale", mandelbrot.Config{XScale: &mandelbrot.SetScale{Min: 1, Max: 1}}},
+		{"Mode", mandelbrot.Config{Mode: "fast"}},
+		{"Depth", mandelbrot.Config{Depth: 7}},
+		{"Samples", mandelbrot.Config{Samples: 100}},
+		{"Adaptive", mandelbrot.Config{Adaptive: 2}},
+	}
+	for _, test := range invalid {
+		err := test.config.Validate()
+		var errs mandelbrot.ValidationError
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != test.field {
+			t.Errorf("%s: got %v", test.field, err)
+		}
+		if _, err := mandelbrot.Create(test.config); err == nil {
+			t.Errorf("%s: Create accepted the config", test.field)
+		}
+	}
+
+	for _, view := range goldenViews {
+		if err := view.config.Validate(); err != nil {
+			t.Errorf("%s: %v", view.name, err)
+		}
+	}
+}
+
+func TestValidateSize(t *testing.T) {
+	// as in the tile example of the readme
+	large := mandelbrot.Config{Width: 20000, Height: 20000}
+	if err := large.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	var errs mandelbrot.ValidationError
+	if err := large.ValidateSize(); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "Width" {
+		t.Errorf("ValidateSize: got %v", err)
+	}
+	if _, err := mandelbrot.Create(large); err == nil {
+		t.Error("Create accepted the config")
+	}
+	if err := (mandelbrot.Config{Scale: 1 << 20}).ValidateSize(); !errors.As(err, &errs) || errs[0].Field != "Scale" {
+		t.Errorf("ValidateSize: got %v", err)
+	}
+
+	tile := large.Region(image.Rect(18432, 18432, 20000, 20000))
+	if err := tile.ValidateSize(); err != nil {
+		t.Errorf("tile: %v", err)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	res := mandelbrot.Config{Width: 64, Height: 48, Samples: 2}.Estimate()
+	if res.Pixels != 64*48 || res.Bytes != 4*64*48 || res.Samples != 4*64*48 {
+		t.Errorf("got %+v", res)
+	}
+	if res.Iterations <= 0 || res.Iterations > res.MaxIterations {
+		t.Errorf("iterations %d, at most %d", res.Iterations, res.MaxIterations)
+	}
+}
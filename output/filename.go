@@ -0,0 +1,226 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// param is one key=value pair of a filename
+type param struct {
+	key    string
+	format func(cfg mandelbrot.Config) string
+	parse  func(cfg *mandelbrot.Config, value string) error
+}
+
+// params lists the encoded parameters in filename order. The first five are
+// the ones older filenames carry, e.g. web-colored_i=120_t=1000_z=1e+06_x=0.243_y=0.8115.jpg
+var params = []param{
+	{"i", func(cfg mandelbrot.Config) string { return strconv.Itoa(cfg.MaxIterations) }, parseInt(func(cfg *mandelbrot.Config, v int) { cfg.MaxIterations = v })},
+	{"t", func(cfg mandelbrot.Config) string { return formatFloat(cfg.Threshold) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.Threshold = v })},
+	{"z", func(cfg mandelbrot.Config) string { return formatFloat(cfg.Zoom) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.Zoom = v })},
+	{"h", func(cfg mandelbrot.Config) string { return formatFloat(cfg.HueOffset) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.HueOffset = v })},
+	{"x", func(cfg mandelbrot.Config) string { return formatFloat(cfg.OffsetX) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.OffsetX = v })},
+	{"y", func(cfg mandelbrot.Config) string { return formatFloat(cfg.OffsetY) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.OffsetY = v })},
+	{"s", formatSize, parseSize},
+	{"sm", func(cfg mandelbrot.Config) string { return strconv.FormatBool(cfg.Smooth) }, func(cfg *mandelbrot.Config, value string) (err error) {
+		cfg.Smooth, err = strconv.ParseBool(value)
+		return err
+	}},
+	{"in", func(cfg mandelbrot.Config) string { return string(cfg.Interior) }, func(cfg *mandelbrot.Config, value string) error {
+		cfg.Interior = mandelbrot.Interior(value)
+		return nil
+	}},
+	{"aa", func(cfg mandelbrot.Config) string { return strconv.Itoa(cfg.Samples) }, parseInt(func(cfg *mandelbrot.Config, v int) { cfg.Samples = v })},
+	{"sp", func(cfg mandelbrot.Config) string { return string(cfg.Sampling) }, func(cfg *mandelbrot.Config, value string) error {
+		cfg.Sampling = mandelbrot.Sampling(value)
+		return nil
+	}},
+	{"f", func(cfg mandelbrot.Config) string { return string(cfg.Filter) }, func(cfg *mandelbrot.Config, value string) error {
+		cfg.Filter = mandelbrot.Filter(value)
+		return nil
+	}},
+	{"ad", func(cfg mandelbrot.Config) string { return formatFloat(cfg.Adaptive) }, parseFloat(func(cfg *mandelbrot.Config, v float64) { cfg.Adaptive = v })},
+	{"d", func(cfg mandelbrot.Config) string { return strconv.Itoa(int(cfg.Depth)) }, parseInt(func(cfg *mandelbrot.Config, v int) { cfg.Depth = mandelbrot.Depth(v) })},
+	{"m", func(cfg mandelbrot.Config) string { return string(cfg.Mode) }, func(cfg *mandelbrot.Config, value string) error {
+		cfg.Mode = mandelbrot.Mode(value)
+		return nil
+	}},
+	{"wk", func(cfg mandelbrot.Config) string { return strconv.Itoa(cfg.Workers) }, parseInt(func(cfg *mandelbrot.Config, v int) { cfg.Workers = v })},
+	{"xs", func(cfg mandelbrot.Config) string { return formatScale(cfg.XScale, mandelbrot.DefaultXScale) }, parseScale(func(cfg *mandelbrot.Config, s *mandelbrot.SetScale) { cfg.XScale = s })},
+	{"ys", func(cfg mandelbrot.Config) string { return formatScale(cfg.YScale, mandelbrot.DefaultYScale) }, parseScale(func(cfg *mandelbrot.Config, s *mandelbrot.SetScale) { cfg.YScale = s })},
+	{"p", formatPalette, func(cfg *mandelbrot.Config, value string) error {
+		cfg.Palette = mandelbrot.Palette(value)
+		return nil
+	}},
+	{"c", formatColors, func(cfg *mandelbrot.Config, value string) error {
+		for _, c := range strings.Split(value, "-") {
+			cfg.Colors = append(cfg.Colors, "#"+c)
+		}
+		return nil
+	}},
+}
+
+// Filename returns filename with every parameter of cfg inserted before the
+// extension, e.g. colored.jpg becomes colored#i=1000_t=4_z=1_h=0_x=0_y=0_s=700x700_....jpg.
+// cfg should be the final config of a render so Width and Height include Scale.
+// Scales are left out while they are the default.
+func Filename(filename string, cfg mandelbrot.Config) string {
+	ext := filepath.Ext(filename)
+	filenameWithoutExt := strings.TrimSuffix(filename, ext)
+
+	pairs := []string{}
+	for _, p := range params {
+		if value := p.format(cfg); value != "" {
+			pairs = append(pairs, p.key+"="+value)
+		}
+	}
+	return filenameWithoutExt + "#" + strings.Join(pairs, "_") + ext
+}
+
+// ParseFilename reconstructs the config encoded by Filename. Names that only
+// carry some of the parameters, like the ones in img/, leave the rest at their
+// zero value so Create fills in the defaults.
+func ParseFilename(filename string) (mandelbrot.Config, error) {
+	name := filepath.Base(filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	encoded, ok := paramsPart(name)
+	if !ok {
+		return mandelbrot.Config{}, fmt.Errorf("no parameters in filename %s", filename)
+	}
+
+	cfg := mandelbrot.Config{}
+	for _, pair := range strings.Split(encoded, "_") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return mandelbrot.Config{}, fmt.Errorf("invalid parameter %q in filename %s", pair, filename)
+		}
+		p, ok := lookup(key)
+		if !ok {
+			return mandelbrot.Config{}, fmt.Errorf("unknown parameter %q in filename %s", key, filename)
+		}
+		if err := p.parse(&cfg, value); err != nil {
+			return mandelbrot.Config{}, fmt.Errorf("invalid parameter %q in filename %s: %w", pair, filename, err)
+		}
+	}
+	return cfg, nil
+}
+
+// paramsPart returns the key=value section of a filename without extension.
+// Parameters follow a '#', or a '_' in names that were renamed by hand.
+func paramsPart(name string) (string, bool) {
+	if _, encoded, ok := strings.Cut(name, "#"); ok {
+		return encoded, true
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '_' {
+			continue
+		}
+		if key, _, ok := strings.Cut(name[i+1:], "="); ok {
+			if _, known := lookup(key); known {
+				return name[i+1:], true
+			}
+		}
+	}
+	return "", false
+}
+
+func lookup(key string) (param, bool) {
+	for _, p := range params {
+		if p.key == key {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatSize(cfg mandelbrot.Config) string {
+	return fmt.Sprintf("%dx%d", cfg.Width, cfg.Height)
+}
+
+func parseSize(cfg *mandelbrot.Config, value string) error {
+	w, h, ok := strings.Cut(value, "x")
+	if !ok {
+		return errors.New("size must be WIDTHxHEIGHT")
+	}
+	var err error
+	if cfg.Width, err = strconv.Atoi(w); err != nil {
+		return err
+	}
+	cfg.Height, err = strconv.Atoi(h)
+	return err
+}
+
+// formatScale writes a scale as min~max, or nothing for the default
+func formatScale(s *mandelbrot.SetScale, def mandelbrot.SetScale) string {
+	if s == nil || *s == def {
+		return ""
+	}
+	return formatFloat(s.Min) + "~" + formatFloat(s.Max)
+}
+
+func parseScale(set func(cfg *mandelbrot.Config, s *mandelbrot.SetScale)) func(cfg *mandelbrot.Config, value string) error {
+	return func(cfg *mandelbrot.Config, value string) error {
+		lo, hi, ok := strings.Cut(value, "~")
+		if !ok {
+			return errors.New("scale must be MIN~MAX")
+		}
+		min, err := strconv.ParseFloat(lo, 64)
+		if err != nil {
+			return err
+		}
+		max, err := strconv.ParseFloat(hi, 64)
+		if err != nil {
+			return err
+		}
+		set(cfg, &mandelbrot.SetScale{Min: min, Max: max})
+		return nil
+	}
+}
+
+func parseInt(set func(cfg *mandelbrot.Config, v int)) func(cfg *mandelbrot.Config, value string) error {
+	return func(cfg *mandelbrot.Config, value string) error {
+		v, err := strconv.Atoi(value)
+		if err == nil {
+			set(cfg, v)
+		}
+		return err
+	}
+}
+
+func parseFloat(set func(cfg *mandelbrot.Config, v float64)) func(cfg *mandelbrot.Config, value string) error {
+	return func(cfg *mandelbrot.Config, value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			set(cfg, v)
+		}
+		return err
+	}
+}
+
+// formatPalette leaves the default palette out, keeping the names of
+// renders from before palettes existed
+func formatPalette(cfg mandelbrot.Config) string {
+	if cfg.Palette == mandelbrot.PaletteHSV {
+		return ""
+	}
+	return string(cfg.Palette)
+}
+
+// formatColors joins the colors without # by -, e.g. 000000-ff8800
+func formatColors(cfg mandelbrot.Config) string {
+	colors := make([]string, len(cfg.Colors))
+	for i, c := range cfg.Colors {
+		colors[i] = strings.TrimPrefix(c, "#")
+	}
+	return strings.Join(colors, "-")
+}
@@ -0,0 +1,85 @@
+package output_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// codecConfigs are configs every parameter of which survives a filename
+var codecConfigs = []struct {
+	name   string
+	config mandelbrot.Config
+}{
+	{"default", mandelbrot.DefaultConfig},
+	{"zero", mandelbrot.Config{}},
+	{"seahorse", mandelbrot.Config{Width: 1920, Height: 1080, Zoom: 200, OffsetX: 0.7435, OffsetY: -0.1315, HueOffset: 200, Smooth: true}},
+	{"negative", mandelbrot.Config{OffsetX: -1.25, OffsetY: -1e-9, HueOffset: -90, Zoom: 1e+06}},
+	{"scales", mandelbrot.Config{XScale: &mandelbrot.SetScale{Min: -0.5, Max: 0.25}, YScale: &mandelbrot.SetScale{Min: -1e-3, Max: 2.5e-3}}},
+	{"sampling", mandelbrot.Config{Samples: 3, Sampling: mandelbrot.SamplingJitter, Filter: mandelbrot.FilterLanczos, Adaptive: 0.05}},
+	{"formula", mandelbrot.Config{MaxIterations: 120, Threshold: 1000, Interior: mandelbrot.InteriorDistance, Depth: mandelbrot.Depth16}},
+	{"mode", mandelbrot.Config{Mode: mandelbrot.Parallel, Workers: 12}},
+	{"palette", mandelbrot.Config{Palette: mandelbrot.PaletteTwilight, HueOffset: 45.5}},
+	{"colors", mandelbrot.Config{Colors: []string{"#000020", "#ff8000", "#ffffff"}}},
+}
+
+func TestFilenameRoundTrip(t *testing.T) {
+	for _, test := range codecConfigs {
+		t.Run(test.name, func(t *testing.T) {
+			want := mandelbrot.Defaults(test.config)
+			filename := output.Filename("img/colored.png", want)
+			if !strings.HasPrefix(filename, "img/colored#") || !strings.HasSuffix(filename, ".png") {
+				t.Fatalf("filename %s does not keep the name and extension", filename)
+			}
+
+			got, err := output.ParseFilename(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mandelbrot.Defaults(got); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s\ngot  %+v\nwant %+v", filename, got, want)
+			}
+		})
+	}
+}
+
+func TestParseFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     mandelbrot.Config
+	}{
+		// names written before the codec existed, see img/
+		{"img/web-colored_i=120_t=1000_z=1e+06_x=0.243_y=0.8115.jpg", mandelbrot.Config{MaxIterations: 120, Threshold: 1000, Zoom: 1e+06, OffsetX: 0.243, OffsetY: 0.8115}},
+		{"zoom_2_i=1000_x=-0.7435.png", mandelbrot.Config{MaxIterations: 1000, OffsetX: -0.7435}},
+		{"a#s=64x48_p=fire.png", mandelbrot.Config{Width: 64, Height: 48, Palette: mandelbrot.PaletteFire}},
+		{"a#c=000000-ff8800.png", mandelbrot.Config{Colors: []string{"#000000", "#ff8800"}}},
+	}
+	for _, test := range tests {
+		got, err := output.ParseFilename(test.filename)
+		if err != nil {
+			t.Errorf("%s: %v", test.filename, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s\ngot  %+v\nwant %+v", test.filename, got, test.want)
+		}
+	}
+}
+
+func TestParseFilenameErrors(t *testing.T) {
+	for _, filename := range []string{
+		"mandelbrot.png",
+		"a#i=1000_q=3.png",
+		"a#i=many.png",
+		"a#i.png",
+		"a#s=700.png",
+		"a#xs=-2.png",
+	} {
+		if cfg, err := output.ParseFilename(filename); err == nil {
+			t.Errorf("%s: no error, parsed %+v", filename, cfg)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package output names and writes rendered images so that their parameters
+// can be recovered from the file, shared by the command line tool and the
+// web server.
+package output
+
+import (
+	"image"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// Metadata returns the text stored in output images, including the full
+// config so the image can be rendered again with -from-image.
+func Metadata(cfg mandelbrot.Config) map[string]string {
+	metadata := map[string]string{
+		imageio.MetadataSoftware: "mandelbrot " + mandelbrot.Version,
+	}
+	if document, err := mandelbrot.EncodeMetadata(cfg); err == nil {
+		metadata[mandelbrot.MetadataKey] = document
+	}
+	return metadata
+}
+
+// Save writes img to filename with the parameters of cfg embedded as metadata.
+func Save(img image.Image, filename string, cfg mandelbrot.Config, opts imageio.Options) error {
+	opts.Metadata = Metadata(cfg)
+	return imageio.Save(img, filename, opts)
+}
+
+// Load returns the config a file was rendered with, read from its metadata
+// or, for files without metadata, from its name.
+func Load(filename string) (mandelbrot.Config, error) {
+	metadata, err := imageio.ReadMetadata(filename)
+	if err == nil {
+		if document, ok := metadata[mandelbrot.MetadataKey]; ok {
+			return mandelbrot.DecodeMetadata(document)
+		}
+	}
+	return ParseFilename(filename)
+}
@@ -0,0 +1,52 @@
+package output_test
+
+import (
+	"image"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+func TestSaveLoad(t *testing.T) {
+	for _, ext := range []string{".png", ".jpg"} {
+		for _, test := range codecConfigs {
+			t.Run(ext+"/"+test.name, func(t *testing.T) {
+				want := mandelbrot.Defaults(test.config)
+				// the name carries nothing, so the config has to come from the metadata
+				filename := filepath.Join(t.TempDir(), "render"+ext)
+				if err := output.Save(image.NewRGBA(image.Rect(0, 0, 4, 3)), filename, want, imageio.Options{}); err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := output.Load(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got  %+v\nwant %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadFromFilename(t *testing.T) {
+	// BMP carries no metadata
+	want := mandelbrot.Defaults(mandelbrot.Config{Zoom: 20, OffsetX: -0.25, Palette: mandelbrot.PaletteOcean})
+	filename := output.Filename(filepath.Join(t.TempDir(), "render.bmp"), want)
+	if err := output.Save(image.NewRGBA(image.Rect(0, 0, 4, 3)), filename, want, imageio.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := output.Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mandelbrot.Defaults(got); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
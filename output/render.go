@@ -0,0 +1,58 @@
+package output
+
+import (
+	"image"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/disintegration/imaging"
+)
+
+// Look is the post-processing of rendered 8 bit images, as percentages
+// passed to imaging.AdjustContrast and imaging.AdjustBrightness. Deeper
+// images are left alone to keep their range.
+type Look struct {
+	Contrast   float64
+	Brightness float64
+}
+
+var (
+	// DefaultLook is the look of images rendered by the command line
+	DefaultLook = Look{Contrast: 2}
+	// ServerLook is the brighter look of images served by the web server
+	ServerLook = Look{Contrast: 20, Brightness: 20}
+)
+
+// Render creates the image for cfg with the default look and returns it ready
+// to be saved, together with the final config after defaults were applied.
+func Render(cfg mandelbrot.Config) (image.Image, mandelbrot.Config, error) {
+	return DefaultLook.Render(cfg)
+}
+
+// Finish applies the default look to a rendered image.
+func Finish(img image.Image, cfg mandelbrot.Config) image.Image {
+	return DefaultLook.Finish(img, cfg)
+}
+
+// Render creates the image for cfg finished with look.
+func (look Look) Render(cfg mandelbrot.Config) (image.Image, mandelbrot.Config, error) {
+	img, err := mandelbrot.Create(cfg)
+	if err != nil {
+		return nil, mandelbrot.Config{}, err
+	}
+	mandel := img.(*mandelbrot.Mandelbrot)
+	return look.Finish(mandel.Image(), mandel.Config), mandel.Config, nil
+}
+
+// Finish applies look to img, the image rendered for cfg.
+func (look Look) Finish(img image.Image, cfg mandelbrot.Config) image.Image {
+	if cfg.Depth != mandelbrot.Depth8 {
+		return img
+	}
+	if look.Contrast != 0 {
+		img = imaging.AdjustContrast(img, look.Contrast)
+	}
+	if look.Brightness != 0 {
+		img = imaging.AdjustBrightness(img, look.Brightness)
+	}
+	return img
+}
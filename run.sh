@@ -6,7 +6,7 @@
 # go run main.go \
 # ./main \
 air -- \
-    --mode pixel \
+    --mode workers \
     --scale 2 \
     --threshold 128 \
     --workers 8 \
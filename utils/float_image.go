@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+)
+
+// RGBF is an in-memory image of linear light float32 RGB pixels, used for
+// high dynamic range output. Channels may exceed 1. At clamps them and
+// converts back to gamma encoded 16-bit sRGB, RGBFAt returns them as stored.
+type RGBF struct {
+	// Pix holds the image's pixels in R, G, B order
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+// NewRGBF returns a new RGBF image with the given bounds.
+func NewRGBF(r image.Rectangle) *RGBF {
+	return &RGBF{
+		Pix:    make([]float32, 3*r.Dx()*r.Dy()),
+		Stride: 3 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+func (p *RGBF) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (p *RGBF) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *RGBF) Opaque() bool {
+	return true
+}
+
+func (p *RGBF) At(x, y int) color.Color {
+	r, g, b := p.RGBFAt(x, y)
+	encode := func(v float32) uint16 {
+		return uint16(LinearToSrgb(ClampFloat(float64(v), 0, 1)) * 0xffff)
+	}
+	return color.RGBA64{encode(r), encode(g), encode(b), 0xffff}
+}
+
+// RGBFAt returns the linear light channels of the pixel at (x, y).
+func (p *RGBF) RGBFAt(x, y int) (float32, float32, float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return 0, 0, 0
+	}
+	i := p.PixOffset(x, y)
+	return p.Pix[i], p.Pix[i+1], p.Pix[i+2]
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds to
+// the pixel at (x, y).
+func (p *RGBF) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*3
+}
+
+func (p *RGBF) Set(x, y int, c color.Color) {
+	r, g, b, _ := c.RGBA()
+	decode := func(v uint32) float32 {
+		return float32(SrgbToLinear(float64(v) / 0xffff))
+	}
+	p.SetRGBF(x, y, decode(r), decode(g), decode(b))
+}
+
+// SetRGBF sets the linear light channels of the pixel at (x, y).
+func (p *RGBF) SetRGBF(x, y int, r, g, b float32) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i], p.Pix[i+1], p.Pix[i+2] = r, g, b
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *RGBF) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &RGBF{}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &RGBF{
+		Pix:    p.Pix[i:],
+		Stride: p.Stride,
+		Rect:   r,
+	}
+}
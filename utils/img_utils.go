@@ -13,8 +13,41 @@ func HsvToRgb(hue float64, saturation float64, value float64) (uint8, uint8, uin
 	return uint8(r * 255.0), uint8(g * 255.0), uint8(b * 255.0)
 }
 
+// HsvToRgbFloat is like HsvToRgb but returns channels in the [0, 1] range
+func HsvToRgbFloat(hue float64, saturation float64, value float64) (float64, float64, float64) {
+	return hsv2rgb(hue, saturation, value)
+}
+
+// HsvToRgbPrecise is like HsvToRgbFloat but skips rounding the channels,
+// for outputs with more than 8 bits per channel
+func HsvToRgbPrecise(hue float64, saturation float64, value float64) (float64, float64, float64) {
+	return hs2rgb(true, hue, saturation, value)
+}
+
+// SrgbToLinear converts a gamma encoded sRGB channel in [0, 1] to linear light
+func SrgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// LinearToSrgb converts a linear light channel in [0, 1] to gamma encoded sRGB
+func LinearToSrgb(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
 func hsv2rgb(hueDegrees float64, saturation float64, value float64) (float64, float64, float64) {
-	return hs2rgb(true, hueDegrees, saturation, value)
+	r, g, b := hs2rgb(true, hueDegrees, saturation, value)
+
+	r = RoundPlaces(r, precision)
+	g = RoundPlaces(g, precision)
+	b = RoundPlaces(b, precision)
+
+	return r, g, b
 }
 
 func hs2rgb(isValue bool, hueDegrees float64, saturation float64, lightOrVal float64) (float64, float64, float64) {
@@ -91,10 +124,6 @@ func hs2rgb(isValue bool, hueDegrees float64, saturation float64, lightOrVal flo
 
 	}
 
-	r = RoundPlaces(r, precision)
-	g = RoundPlaces(g, precision)
-	b = RoundPlaces(b, precision)
-
 	return r, g, b
 }
 
@@ -0,0 +1,387 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
+)
+
+// maxRequestBytes bounds the body of render requests
+const maxRequestBytes = 1 << 20
+
+// rendersPath serves the images of JSON responses, named by renderURL
+const rendersPath = "/api/renders/"
+
+// statsExt follows the cache key of an image in the key of the stats of its
+// JSON response. It is not an image extension, so the stats stay in memory.
+const statsExt = ".stats"
+
+// renderRequest holds the options of a render request besides the config
+// fields, which are at the top level of the body
+type renderRequest struct {
+	Palette *apiPalette
+	Formula *apiFormula
+	// Format is the image extension, e.g. ".png"
+	Format  string
+	Quality int
+	// Response is "image" for the image itself or "json" for the statistics
+	// and a link to the image
+	Response string
+}
+
+// apiPalette groups the color options. It may also be given as just the
+// palette name.
+type apiPalette struct {
+	Name mandelbrot.Palette `json:"name"`
+	Hue  *float64           `json:"hue"`
+	// Colors are #rrggbb stops of a custom gradient
+	Colors []string `json:"colors"`
+}
+
+// apiFormula groups the options of the iteration
+type apiFormula struct {
+	Iterations *int                `json:"iterations"`
+	Threshold  *float64            `json:"threshold"`
+	Smooth     *bool               `json:"smooth"`
+	Interior   mandelbrot.Interior `json:"interior"`
+}
+
+// renderResponse is the JSON response of a render
+type renderResponse struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Seconds is the time the render took, also when the response comes from
+	// the cache
+	Seconds float64 `json:"seconds"`
+	// Samples is the number of points iterated, more than the pixels when
+	// supersampling
+	Samples          int64   `json:"samples"`
+	Iterations       int64   `json:"iterations"`
+	InteriorFraction float64 `json:"interiorFraction"`
+	// URL downloads the image, from the cache or rendered again
+	URL string `json:"url"`
+	// Config is the config the render used, with every default filled in, so
+	// it can be posted again
+	Config mandelbrot.Config `json:"config"`
+}
+
+// renderToken is what a render URL encodes, enough to render the image again
+type renderToken struct {
+	Config  mandelbrot.Config `json:"c"`
+	Quality int               `json:"q,omitempty"`
+}
+
+// apiError is the JSON body of failed API requests
+type apiError struct {
+	Error  string          `json:"error"`
+	Fields []apiFieldError `json:"fields,omitempty"`
+}
+
+type apiFieldError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// apiHandler serves POST /api/render. The body is a JSON object of config
+// fields, as in config files, together with the options of renderRequest:
+//
+//	{
+//		"width": 800, "height": 600, "zoom": 1000, "offsetX": 0.7435, "offsetY": -0.1315,
+//		"palette": {"colors": ["#000020", "#ff8000", "#ffffff"], "hue": 40},
+//		"formula": {"iterations": 2000, "smooth": true},
+//		"format": ".png", "response": "json"
+//	}
+//
+// Unknown fields and values of the wrong type are errors. The grouped
+// options apply after the config fields.
+func apiHandler(w http.ResponseWriter, r *http.Request, opts Options, limits *limiter) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, &statusError{http.StatusMethodNotAllowed, errors.New("use POST with a JSON body")})
+		return
+	}
+
+	config, req, err := parseRenderRequest(http.MaxBytesReader(w, r.Body, maxRequestBytes), opts)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	encodeOpts := imageio.Options{Quality: req.Quality, Compression: opts.Compression}
+
+	if req.Response == "image" {
+		// shares the cache with GET requests
+		serveImage(w, r, config, req.Format, encodeOpts, opts, limits, writeAPIError)
+		return
+	}
+
+	if err := checkConfig(config, limits); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var res renderResponse
+	if opts.Cache == nil {
+		res, err = renderStats(r.Context(), config, req.Format, encodeOpts, opts, limits)
+	} else {
+		res, err = cachedStats(w, config, req.Format, encodeOpts, opts, limits)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	// the stats may come from a request that split the work differently
+	cfg := mandelbrot.Defaults(config)
+	res.Config.Mode, res.Config.Workers = cfg.Mode, cfg.Workers
+	res.URL, err = renderURL(config, req.Format, req.Quality)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// cachedStats returns the response to a JSON render request from the cache,
+// or renders the image and caches both. The stats are kept in memory next to
+// the image, under its key with statsExt, so repeated requests do not render
+// again even after the image was downloaded and evicted.
+func cachedStats(w http.ResponseWriter, config mandelbrot.Config, ext string, encodeOpts imageio.Options, opts Options, limits *limiter) (renderResponse, error) {
+	key, err := cacheKey(config, ext, encodeOpts)
+	if err != nil {
+		return renderResponse{}, err
+	}
+	data, hit, err := opts.Cache.Get(key+statsExt, func() ([]byte, error) {
+		// other requests may wait for this render, so it goes on if the
+		// client leaves
+		res, err := renderStats(context.Background(), config, ext, encodeOpts, opts, limits)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(res)
+	})
+	if err != nil {
+		return renderResponse{}, err
+	}
+
+	if hit {
+		w.Header().Set("X-Cache", "hit")
+	} else {
+		w.Header().Set("X-Cache", "miss")
+	}
+	var res renderResponse
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+// renderStats renders config and returns the response to a JSON render
+// request, without its URL. With a cache the image is cached right away as it
+// is likely downloaded next.
+func renderStats(ctx context.Context, config mandelbrot.Config, ext string, encodeOpts imageio.Options, opts Options, limits *limiter) (renderResponse, error) {
+	release, err := limits.acquire(ctx)
+	if err != nil {
+		return renderResponse{}, err
+	}
+	tStart := time.Now()
+	img, err := mandelbrot.Create(config)
+	release()
+	if err != nil {
+		return renderResponse{}, err
+	}
+	seconds := time.Since(tStart).Seconds()
+
+	mandel := img.(*mandelbrot.Mandelbrot)
+	stats := mandel.Stats()
+	res := renderResponse{
+		Width:            mandel.Config.Width,
+		Height:           mandel.Config.Height,
+		Seconds:          seconds,
+		Samples:          stats.Samples,
+		Iterations:       stats.Iterations,
+		InteriorFraction: float64(stats.Interior) / float64(stats.Samples),
+		Config:           mandelbrot.Defaults(mandel.Config),
+	}
+
+	if opts.Cache != nil {
+		key, err := cacheKey(config, ext, encodeOpts)
+		if err != nil {
+			return renderResponse{}, err
+		}
+		encodeOpts.Metadata = output.Metadata(normalizeConfig(mandel.Config))
+		var buf bytes.Buffer
+		if err := imageio.Encode(&buf, output.ServerLook.Finish(mandel.Image(), mandel.Config), ext, encodeOpts); err != nil {
+			return renderResponse{}, err
+		}
+		opts.Cache.Put(key, buf.Bytes())
+	}
+	return res, nil
+}
+
+// parseRenderRequest reads the config and options of a render request
+func parseRenderRequest(body io.Reader, opts Options) (mandelbrot.Config, renderRequest, error) {
+	badRequest := func(err error) error {
+		return &statusError{http.StatusBadRequest, err}
+	}
+
+	var fields map[string]json.RawMessage
+	decoder := json.NewDecoder(body)
+	if err := decoder.Decode(&fields); err != nil {
+		return mandelbrot.Config{}, renderRequest{}, badRequest(fmt.Errorf("invalid JSON body: %w", err))
+	}
+	if err := decoder.Decode(&json.RawMessage{}); err != io.EOF {
+		return mandelbrot.Config{}, renderRequest{}, badRequest(errors.New("invalid JSON body: data after the object"))
+	}
+
+	req := renderRequest{
+		Format:   filepath.Ext(opts.Out),
+		Quality:  opts.Quality,
+		Response: "image",
+	}
+	// the options are taken out, the rest are config fields
+	options := map[string]any{
+		"palette":  &req.Palette,
+		"formula":  &req.Formula,
+		"format":   &req.Format,
+		"quality":  &req.Quality,
+		"response": &req.Response,
+	}
+	for key, value := range fields {
+		target, ok := options[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		delete(fields, key)
+		if p, ok := target.(**apiPalette); ok && bytes.HasPrefix(bytes.TrimSpace(value), []byte(`"`)) {
+			*p = &apiPalette{}
+			target = &(*p).Name
+		}
+		if err := decodeStrict(value, target); err != nil {
+			return mandelbrot.Config{}, renderRequest{}, badRequest(fmt.Errorf("invalid %s: %w", strings.ToLower(key), err))
+		}
+	}
+
+	if !strings.HasPrefix(req.Format, ".") {
+		req.Format = "." + req.Format
+	}
+	if imageio.ContentType(req.Format) == "" {
+		return mandelbrot.Config{}, renderRequest{}, badRequest(fmt.Errorf("unsupported format %q", req.Format))
+	}
+	if req.Response != "image" && req.Response != "json" {
+		return mandelbrot.Config{}, renderRequest{}, badRequest(fmt.Errorf("response must be image or json, not %q", req.Response))
+	}
+
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return mandelbrot.Config{}, renderRequest{}, err
+	}
+	config, err := mandelbrot.Override(opts.Defaults, rest)
+	if err != nil {
+		return mandelbrot.Config{}, renderRequest{}, badRequest(fmt.Errorf("invalid config: %w", err))
+	}
+
+	if p := req.Palette; p != nil {
+		if p.Name != "" {
+			config.Palette = p.Name
+		}
+		if p.Hue != nil {
+			config.HueOffset = *p.Hue
+		}
+		if p.Colors != nil {
+			config.Colors = p.Colors
+		}
+	}
+	if f := req.Formula; f != nil {
+		if f.Iterations != nil {
+			config.MaxIterations = *f.Iterations
+		}
+		if f.Threshold != nil {
+			config.Threshold = *f.Threshold
+		}
+		if f.Smooth != nil {
+			config.Smooth = *f.Smooth
+		}
+		if f.Interior != "" {
+			config.Interior = f.Interior
+		}
+	}
+	return config, req, nil
+}
+
+// decodeStrict decodes data into v, failing on unknown fields
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// writeAPIError responds with err as JSON, listing the invalid fields of
+// validation errors
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.status
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	body := apiError{Error: err.Error()}
+	var invalid mandelbrot.ValidationError
+	if errors.As(err, &invalid) {
+		for _, field := range invalid {
+			body.Fields = append(body.Fields, apiFieldError{Field: field.Field, Value: fmt.Sprint(field.Value), Reason: field.Reason})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// renderURL returns the URL of the image of config encoded as ext. The URL
+// carries the config, so it works without a cache and after the image was
+// evicted from it.
+func renderURL(config mandelbrot.Config, ext string, quality int) (string, error) {
+	token, err := json.Marshal(renderToken{Config: config, Quality: quality})
+	if err != nil {
+		return "", err
+	}
+	return rendersPath + base64.RawURLEncoding.EncodeToString(token) + ext, nil
+}
+
+// rendersHandler serves the images linked from JSON responses, like
+// /mandelbrot from the cache or rendered again
+func rendersHandler(w http.ResponseWriter, r *http.Request, opts Options, limits *limiter) {
+	name := strings.TrimPrefix(r.URL.Path, rendersPath)
+	ext := filepath.Ext(name)
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(name, ext))
+	var token renderToken
+	if err != nil || imageio.ContentType(ext) == "" || decodeStrict(data, &token) != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	serveImage(w, r, token.Config, ext, imageio.Options{
+		Quality:     token.Quality,
+		Compression: opts.Compression,
+	}, opts, limits, writeAPIError)
+}
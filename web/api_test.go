@@ -0,0 +1,52 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/web"
+)
+
+func TestAPIStatsCached(t *testing.T) {
+	cache, err := web.NewCache(1<<20, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := web.Handler(web.Options{Out: ".png", Cache: cache})
+	post := func(body string) (map[string]any, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/render", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var res map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res, w.Header().Get("X-Cache")
+	}
+
+	first, status := post(`{"width": 16, "height": 12, "mode": "row", "response": "json"}`)
+	if status != "miss" {
+		t.Errorf("first request is a %s, want a miss", status)
+	}
+	second, status := post(`{"width": 16, "height": 12, "mode": "workers", "response": "json"}`)
+	if status != "hit" {
+		t.Errorf("second request is a %s, want a hit", status)
+	}
+	for _, field := range []string{"seconds", "samples", "iterations", "interiorFraction"} {
+		if first[field] != second[field] {
+			t.Errorf("%s is %v, then %v", field, first[field], second[field])
+		}
+	}
+	// the config and URL are the ones of each request
+	if mode := second["config"].(map[string]any)["mode"]; mode != "workers" {
+		t.Errorf("mode %v, want workers", mode)
+	}
+	if first["url"] == second["url"] {
+		t.Error("both requests got the same url")
+	}
+}
@@ -0,0 +1,301 @@
+package web
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+// Cache keeps encoded images by key, the most recently used in memory and,
+// with a directory, more on disk. Both tiers drop the least recently used
+// images when they exceed their size. Only images under keys made by cacheKey
+// go to disk, where the key is the file name, other entries such as the stats
+// of JSON responses stay in memory.
+type Cache struct {
+	memoryLimit int64
+	dir         string
+	diskLimit   int64
+
+	mu     sync.Mutex
+	memory tier
+	disk   tier
+	// renders in progress, requests for the same key wait for them
+	inflight map[string]*cacheCall
+}
+
+// tier is an LRU index of cached sizes, the front is the most recent
+type tier struct {
+	entries map[string]*list.Element
+	order   *list.List
+	size    int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+	// data is nil on disk
+	data []byte
+}
+
+type cacheCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewCache returns a cache holding up to memory bytes in memory. With a dir
+// images evicted from memory are kept there up to disk bytes, including the
+// ones cached by earlier runs. Other files in dir are left alone.
+func NewCache(memory int64, dir string, disk int64) (*Cache, error) {
+	c := &Cache{
+		memoryLimit: memory,
+		dir:         dir,
+		diskLimit:   disk,
+		memory:      newTier(),
+		disk:        newTier(),
+		inflight:    map[string]*cacheCall{},
+	}
+	if dir == "" {
+		return c, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// the modification time is the last use, the oldest go first
+	infos := []os.FileInfo{}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() || !isCacheKey(info.Name()) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	for _, info := range infos {
+		c.disk.add(&cacheEntry{key: info.Name(), size: info.Size()})
+	}
+	c.evictDisk()
+	return c, nil
+}
+
+func newTier() tier {
+	return tier{entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (t *tier) add(entry *cacheEntry) {
+	t.entries[entry.key] = t.order.PushFront(entry)
+	t.size += entry.size
+}
+
+func (t *tier) get(key string) (*cacheEntry, bool) {
+	element, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	t.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+// oldest removes and returns the least recently used entry
+func (t *tier) oldest() *cacheEntry {
+	element := t.order.Back()
+	entry := t.order.Remove(element).(*cacheEntry)
+	delete(t.entries, entry.key)
+	t.size -= entry.size
+	return entry
+}
+
+func (t *tier) remove(key string) {
+	if element, ok := t.entries[key]; ok {
+		t.order.Remove(element)
+		delete(t.entries, key)
+		t.size -= element.Value.(*cacheEntry).size
+	}
+}
+
+// Get returns the image cached under key, or calls render and caches its
+// result. Concurrent calls for the same key render it once. hit reports
+// whether the image came from the cache.
+func (c *Cache) Get(key string, render func() ([]byte, error)) (data []byte, hit bool, err error) {
+	c.mu.Lock()
+	if entry, ok := c.memory.get(key); ok {
+		c.mu.Unlock()
+		return entry.data, true, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.data, false, call.err
+	}
+	_, onDisk := c.disk.get(key)
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	if onDisk {
+		if call.data, call.err = c.readDisk(key); call.err == nil {
+			hit = true
+		}
+	}
+	if !hit {
+		call.data, call.err = render()
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.store(key, call.data, !hit)
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.data, hit, call.err
+}
+
+// Load returns the image cached under key, without rendering it.
+func (c *Cache) Load(key string) ([]byte, bool) {
+	c.mu.Lock()
+	if entry, ok := c.memory.get(key); ok {
+		c.mu.Unlock()
+		return entry.data, true
+	}
+	_, onDisk := c.disk.get(key)
+	c.mu.Unlock()
+	if !onDisk {
+		return nil, false
+	}
+
+	data, err := c.readDisk(key)
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.store(key, data, false)
+	c.mu.Unlock()
+	return data, true
+}
+
+// Put caches data under key, replacing the image cached under it.
+func (c *Cache) Put(key string, data []byte) {
+	c.mu.Lock()
+	c.store(key, data, true)
+	c.mu.Unlock()
+}
+
+// store adds data to memory and, if it is new, to disk. c.mu must be held.
+func (c *Cache) store(key string, data []byte, write bool) {
+	size := int64(len(data))
+	c.memory.remove(key)
+	if size <= c.memoryLimit {
+		c.memory.add(&cacheEntry{key: key, size: size, data: data})
+		for c.memory.size > c.memoryLimit {
+			c.memory.oldest()
+		}
+	}
+
+	if c.dir == "" || !write || size > c.diskLimit || !isCacheKey(key) {
+		return
+	}
+	if err := c.writeDisk(key, data); err != nil {
+		return
+	}
+	c.disk.remove(key)
+	c.disk.add(&cacheEntry{key: key, size: size})
+	c.evictDisk()
+}
+
+// evictDisk deletes the least recently used files over the disk limit. c.mu
+// must be held.
+func (c *Cache) evictDisk() {
+	for c.disk.size > c.diskLimit {
+		os.Remove(filepath.Join(c.dir, c.disk.oldest().key))
+	}
+}
+
+func (c *Cache) readDisk(key string) ([]byte, error) {
+	filename := filepath.Join(c.dir, key)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		c.mu.Lock()
+		c.disk.remove(key)
+		c.mu.Unlock()
+		return nil, err
+	}
+	// keeps the order of use across restarts
+	now := time.Now()
+	os.Chtimes(filename, now, now)
+	return data, nil
+}
+
+// writeDisk writes under a temporary name first, so files are always complete
+func (c *Cache) writeDisk(key string, data []byte) error {
+	f, err := os.CreateTemp(c.dir, ".partial-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// cacheKey identifies the image encoded from config as ext with opts, as a
+// hex SHA-256 hash followed by ext, e.g. 3fa2...e1.png. The config is
+// normalized first, so requests that render the same pixels share a key
+// whatever their mode or number of workers.
+func cacheKey(config mandelbrot.Config, ext string, opts imageio.Options) (string, error) {
+	document, err := json.Marshal(normalizeConfig(config))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n%d\n%s\n", mandelbrot.Version, document, strings.ToLower(ext), opts.Quality, opts.Compression)
+	return hex.EncodeToString(hash.Sum(nil)) + strings.ToLower(ext), nil
+}
+
+// normalizeConfig fills in the defaults of config and sets the fields that
+// do not change the pixels to fixed values. Cached images embed the
+// normalized config, so their metadata does not depend on which of the
+// requests sharing a key rendered them.
+func normalizeConfig(config mandelbrot.Config) mandelbrot.Config {
+	cfg := mandelbrot.Defaults(config)
+	cfg.Mode, cfg.Workers = mandelbrot.DefaultConfig.Mode, mandelbrot.DefaultConfig.Workers
+	// -0 and 0 render the same
+	cfg.OffsetX += 0
+	cfg.OffsetY += 0
+	cfg.HueOffset += 0
+	return cfg
+}
+
+// isCacheKey reports whether name has the form of the keys made by cacheKey
+func isCacheKey(name string) bool {
+	ext := filepath.Ext(name)
+	hash := strings.TrimSuffix(name, ext)
+	if len(hash) != 2*sha256.Size || ext != strings.ToLower(ext) || imageio.ContentType(ext) == "" {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && hash == strings.ToLower(hash)
+}
@@ -0,0 +1,102 @@
+package web_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/web"
+)
+
+func TestCacheKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	entry := strings.Repeat("0123456789abcdef", 4) + ".png"
+	files := map[string]string{
+		entry:                   "cached image",
+		"mandelbrot.png":        "user image",
+		"notes.txt":             "user notes",
+		strings.Repeat("a", 64): "no extension",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// no room on disk, so every adopted file is evicted
+	cache, err := web.NewCache(1<<20, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(strings.Repeat("f", 64)+".png", []byte("new image"))
+
+	for name, data := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if name == entry {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s was not evicted", name)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, []byte(data)) {
+			t.Errorf("%s was changed: %q, %v", name, got, err)
+		}
+	}
+	if _, ok := cache.Load("mandelbrot.png"); ok {
+		t.Error("a file that is not a cache entry was loaded")
+	}
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	key := strings.Repeat("0123456789abcdef", 4) + ".jpg"
+
+	cache, err := web.NewCache(0, dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(key, []byte("image"))
+
+	// a new cache finds the entry of the previous one
+	cache, err = web.NewCache(0, dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := cache.Load(key); !ok || string(data) != "image" {
+		t.Errorf("got %q, %v", data, ok)
+	}
+}
+
+func TestCacheIgnoresMode(t *testing.T) {
+	// requests that only differ in how the work is split share an entry,
+	// which is the same whichever of them rendered it
+	get := func(handler http.Handler, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mandelbrot?width=16&height=12&iterations=50&"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, w.Code, w.Body)
+		}
+		return w
+	}
+	newHandler := func() http.Handler {
+		cache, err := web.NewCache(1<<20, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return web.Handler(web.Options{Out: ".png", Cache: cache})
+	}
+
+	handler := newHandler()
+	rows := get(handler, "mode=row&workers=3")
+	workers := get(handler, "mode=workers&workers=7")
+	if workers.Header().Get("X-Cache") != "hit" || !bytes.Equal(rows.Body.Bytes(), workers.Body.Bytes()) {
+		t.Errorf("mode workers is a %s, want a hit on mode row", workers.Header().Get("X-Cache"))
+	}
+	if seq := get(newHandler(), "mode=seq"); !bytes.Equal(seq.Body.Bytes(), rows.Body.Bytes()) {
+		t.Error("the image rendered in mode row differs from the one rendered in mode seq")
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+)
+
+//go:embed explorer
+var explorerFiles embed.FS
+
+var explorerTemplate = template.Must(template.ParseFS(explorerFiles, "explorer/index.html"))
+
+// explorerView is the view the explorer starts from, with the names of the
+// query parameters of /mandelbrot
+type explorerView struct {
+	Iterations int                `json:"iterations"`
+	Hue        float64            `json:"hue"`
+	Threshold  float64            `json:"threshold"`
+	Palette    mandelbrot.Palette `json:"palette"`
+	Smooth     bool               `json:"smooth"`
+	Zoom       float64            `json:"zoom"`
+	OffsetX    float64            `json:"offsetX"`
+	OffsetY    float64            `json:"offsetY"`
+	// the scales map pixels to the plane and cannot be changed by requests
+	XMin float64 `json:"xMin"`
+	XMax float64 `json:"xMax"`
+	YMin float64 `json:"yMin"`
+	YMax float64 `json:"yMax"`
+}
+
+// explorerHandler serves the explorer page at / and its scripts and styles
+// under /static/
+func explorerHandler(opts Options) http.Handler {
+	static, err := fs.Sub(explorerFiles, "explorer")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+
+	cfg := mandelbrot.Defaults(opts.Defaults)
+	data := struct {
+		Palettes []mandelbrot.Palette
+		View     explorerView
+	}{
+		Palettes: mandelbrot.Palettes,
+		View: explorerView{
+			Iterations: cfg.MaxIterations,
+			Hue:        cfg.HueOffset,
+			Threshold:  cfg.Threshold,
+			Palette:    cfg.Palette,
+			Smooth:     cfg.Smooth,
+			Zoom:       cfg.Zoom,
+			OffsetX:    cfg.OffsetX,
+			OffsetY:    cfg.OffsetY,
+			XMin:       cfg.XScale.Min,
+			XMax:       cfg.XScale.Max,
+			YMin:       cfg.YScale.Min,
+			YMax:       cfg.YScale.Max,
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := explorerTemplate.Execute(w, data); err != nil {
+				log.Println("Explorer page:", err)
+			}
+		case r.URL.Path == "/static/index.html":
+			http.NotFound(w, r)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	})
+}
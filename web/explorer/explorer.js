@@ -0,0 +1,291 @@
+// Explorer of the set, rendering views through /mandelbrot. The view lives in
+// the query string of the page, so the address is always a link to it.
+(() => {
+	"use strict";
+
+	// parameters of the view, shared by the page and /mandelbrot
+	const numbers = ["zoom", "offsetX", "offsetY", "iterations", "hue", "threshold"];
+	const maxSize = 1024;
+
+	const viewport = document.getElementById("viewport");
+	const img = document.getElementById("view");
+	const status = document.getElementById("status");
+	const controls = {
+		iterations: document.getElementById("iterations"),
+		hue: document.getElementById("hue"),
+		threshold: document.getElementById("threshold"),
+		palette: document.getElementById("palette"),
+		smooth: document.getElementById("smooth"),
+	};
+
+	let view = readView();
+	let size = 0;
+	// preview maps the displayed image onto the view being rendered, so
+	// interactions show at once
+	let preview = new DOMMatrix();
+	let pending = null;
+
+	function defaultView() {
+		const v = {};
+		for (const key of numbers) {
+			v[key] = DEFAULTS[key];
+		}
+		v.palette = DEFAULTS.palette;
+		v.smooth = DEFAULTS.smooth;
+		return v;
+	}
+
+	function readView() {
+		const v = defaultView();
+		const query = new URLSearchParams(location.search);
+		for (const key of numbers) {
+			const value = parseFloat(query.get(key));
+			if (Number.isFinite(value)) {
+				v[key] = value;
+			}
+		}
+		if (query.has("palette")) {
+			v.palette = query.get("palette");
+		}
+		if (query.has("smooth")) {
+			v.smooth = query.get("smooth") === "true";
+		}
+		return v;
+	}
+
+	function viewParams() {
+		const params = new URLSearchParams();
+		for (const key of numbers) {
+			params.set(key, String(view[key]));
+		}
+		params.set("palette", view.palette);
+		params.set("smooth", String(view.smooth));
+		return params;
+	}
+
+	// span returns the width of a pixel before zooming, along x or y
+	function span(axis) {
+		return axis === "x" ? (DEFAULTS.xMax - DEFAULTS.xMin) / size : (DEFAULTS.yMax - DEFAULTS.yMin) / size;
+	}
+
+	// point returns the complex coordinates of pixel (px, py), as in Create
+	function point(px, py) {
+		return {
+			x: (DEFAULTS.xMin + px * span("x")) / view.zoom - view.offsetX,
+			y: (DEFAULTS.yMin + py * span("y")) / view.zoom - view.offsetY,
+		};
+	}
+
+	// zoomAround multiplies the zoom by k, keeping pixel (px, py) in place
+	function zoomAround(px, py, k) {
+		const before = point(px, py);
+		view.zoom *= k;
+		const after = point(px, py);
+		view.offsetX += after.x - before.x;
+		view.offsetY += after.y - before.y;
+		transform(new DOMMatrix().translate(px, py).scale(k).translate(-px, -py));
+	}
+
+	// zoomTo multiplies the zoom by k and moves pixel (px, py) to the center
+	function zoomTo(px, py, k) {
+		const target = point(px, py);
+		view.zoom *= k;
+		const center = point(size / 2, size / 2);
+		view.offsetX += center.x - target.x;
+		view.offsetY += center.y - target.y;
+		transform(new DOMMatrix().translate(size / 2, size / 2).scale(k).translate(-px, -py));
+	}
+
+	// pan moves the view with the pointer by (dx, dy) pixels
+	function pan(dx, dy) {
+		view.offsetX += dx * span("x") / view.zoom;
+		view.offsetY += dy * span("y") / view.zoom;
+		transform(new DOMMatrix().translate(dx, dy));
+	}
+
+	function transform(m) {
+		preview = m.multiply(preview);
+		img.style.transform = preview.toString();
+	}
+
+	// local returns the position of a pointer event on the image
+	function local(event) {
+		const box = viewport.getBoundingClientRect();
+		return {
+			x: event.clientX - box.left - img.offsetLeft,
+			y: event.clientY - box.top - img.offsetTop,
+		};
+	}
+
+	function showStatus(text, error) {
+		status.textContent = text;
+		status.classList.toggle("error", Boolean(error));
+	}
+
+	function syncControls() {
+		controls.iterations.value = view.iterations;
+		controls.hue.value = view.hue;
+		controls.threshold.value = view.threshold;
+		controls.palette.value = view.palette;
+		controls.smooth.checked = view.smooth;
+		for (const key of ["iterations", "hue", "threshold"]) {
+			document.getElementById(key + "Value").textContent = controls[key].value;
+		}
+		const center = point(size / 2, size / 2);
+		document.getElementById("zoom").textContent = view.zoom.toPrecision(6);
+		document.getElementById("center").textContent = `${center.x} ${center.y < 0 ? "-" : "+"} ${Math.abs(center.y)}i`;
+	}
+
+	async function render() {
+		size = Math.max(64, Math.min(maxSize, viewport.clientWidth, viewport.clientHeight));
+		img.width = img.height = size;
+
+		const params = viewParams();
+		history.replaceState(null, "", "?" + params.toString());
+		syncControls();
+
+		params.set("width", size);
+		params.set("height", size);
+		params.set("scale", 1);
+		params.set("out", ".png");
+		document.getElementById("download").href = "/mandelbrot?" + params.toString();
+
+		if (pending) {
+			pending.abort();
+		}
+		const controller = new AbortController();
+		pending = controller;
+		const started = performance.now();
+		showStatus("Rendering…");
+
+		try {
+			const response = await fetch("/mandelbrot?" + params.toString(), { signal: controller.signal });
+			if (!response.ok) {
+				showStatus(`${response.status}: ${(await response.text()).trim()}`, true);
+				return;
+			}
+			const blob = await response.blob();
+			if (pending !== controller) {
+				return;
+			}
+			const previous = img.src;
+			img.src = URL.createObjectURL(blob);
+			await img.decode().catch(() => {});
+			if (previous.startsWith("blob:")) {
+				URL.revokeObjectURL(previous);
+			}
+			preview = new DOMMatrix();
+			img.style.transform = "";
+			showStatus(`${Math.round(performance.now() - started)} ms`);
+		} catch (err) {
+			if (err.name !== "AbortError") {
+				showStatus(err.message, true);
+			}
+		} finally {
+			if (pending === controller) {
+				pending = null;
+			}
+		}
+	}
+
+	// dragging pans, a press without moving zooms
+	let drag = null;
+	viewport.addEventListener("pointerdown", (event) => {
+		if (event.button !== 0) {
+			return;
+		}
+		viewport.setPointerCapture(event.pointerId);
+		drag = { start: local(event), moved: false, shift: event.shiftKey };
+	});
+	viewport.addEventListener("pointermove", (event) => {
+		if (!drag) {
+			return;
+		}
+		const p = local(event);
+		const dx = p.x - drag.start.x;
+		const dy = p.y - drag.start.y;
+		if (Math.hypot(dx, dy) > 4) {
+			drag.moved = true;
+			viewport.classList.add("dragging");
+		}
+		if (drag.moved) {
+			img.style.transform = new DOMMatrix().translate(dx, dy).multiply(preview).toString();
+		}
+	});
+	viewport.addEventListener("pointerup", (event) => {
+		if (!drag) {
+			return;
+		}
+		const p = local(event);
+		if (drag.moved) {
+			pan(p.x - drag.start.x, p.y - drag.start.y);
+		} else {
+			zoomTo(p.x, p.y, drag.shift ? 0.5 : 2);
+		}
+		drag = null;
+		viewport.classList.remove("dragging");
+		render();
+	});
+	viewport.addEventListener("pointercancel", () => {
+		drag = null;
+		viewport.classList.remove("dragging");
+		img.style.transform = preview.toString();
+	});
+
+	let wheelTimer = 0;
+	viewport.addEventListener("wheel", (event) => {
+		event.preventDefault();
+		const p = local(event);
+		zoomAround(p.x, p.y, Math.pow(2, -event.deltaY / 300));
+		syncControls();
+		clearTimeout(wheelTimer);
+		wheelTimer = setTimeout(render, 200);
+	}, { passive: false });
+
+	for (const key of ["iterations", "hue", "threshold"]) {
+		controls[key].addEventListener("input", () => {
+			document.getElementById(key + "Value").textContent = controls[key].value;
+		});
+		controls[key].addEventListener("change", () => {
+			view[key] = parseFloat(controls[key].value);
+			render();
+		});
+	}
+	controls.palette.addEventListener("change", () => {
+		view.palette = controls.palette.value;
+		render();
+	});
+	controls.smooth.addEventListener("change", () => {
+		view.smooth = controls.smooth.checked;
+		render();
+	});
+
+	document.getElementById("reset").addEventListener("click", () => {
+		view = defaultView();
+		preview = new DOMMatrix();
+		img.style.transform = "";
+		render();
+	});
+
+	document.getElementById("share").addEventListener("click", async () => {
+		try {
+			await navigator.clipboard.writeText(location.href);
+			showStatus("Link copied");
+		} catch {
+			// the clipboard needs https or localhost
+			window.prompt("Copy the link to this view", location.href);
+		}
+	});
+
+	let resizeTimer = 0;
+	window.addEventListener("resize", () => {
+		clearTimeout(resizeTimer);
+		resizeTimer = setTimeout(() => {
+			preview = new DOMMatrix();
+			img.style.transform = "";
+			render();
+		}, 200);
+	});
+
+	render();
+})();
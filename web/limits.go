@@ -0,0 +1,236 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// Limits bounds the work a request can cause. Zero fields are unlimited.
+type Limits struct {
+	// MaxPixels is the largest image, after scaling
+	MaxPixels int64
+	// MaxSamples bounds the points iterated, pixels times sub-pixel samples
+	MaxSamples    int64
+	MaxIterations int
+	// MaxGoroutines bounds the goroutines a render starts, e.g. one per
+	// pixel in the pixel mode
+	MaxGoroutines int
+
+	// Renders is the number of renders running at once
+	Renders int
+	// Queue is the number of requests waiting for a render, more are
+	// rejected with 503
+	Queue int
+	// QueueTimeout rejects requests that waited longer with 503
+	QueueTimeout time.Duration
+
+	// Rate is the requests per second a client may make on average, more are
+	// rejected with 429
+	Rate float64
+	// Burst is the number of requests a client may make at once
+	Burst int
+	// TileRate and TileBurst limit tile requests like Rate and Burst, apart
+	// from the others as map viewers request a screen of tiles at once
+	TileRate  float64
+	TileBurst int
+}
+
+// statusError is an error responded with a status other than 500
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// writeError responds with err, with its status if it is a statusError
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.status
+	}
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// limiter enforces Limits across requests
+type limiter struct {
+	Limits
+	// tickets are held by running and waiting requests, slots by running ones
+	tickets chan struct{}
+	slots   chan struct{}
+	clients *rateLimiter
+	tiles   *rateLimiter
+}
+
+func newLimiter(limits Limits) *limiter {
+	l := &limiter{Limits: limits}
+	if limits.Renders > 0 {
+		l.tickets = make(chan struct{}, limits.Renders+limits.Queue)
+		l.slots = make(chan struct{}, limits.Renders)
+	}
+	if limits.Rate > 0 {
+		l.clients = newRateLimiter(limits.Rate, utils.Max(limits.Burst, 1))
+	}
+	if limits.TileRate > 0 {
+		l.tiles = newRateLimiter(limits.TileRate, utils.Max(limits.TileBurst, 1))
+	}
+	return l
+}
+
+// check returns an error if rendering config exceeds the limits, config must
+// be valid
+func (l *limiter) check(config mandelbrot.Config) error {
+	cfg := mandelbrot.Defaults(config)
+	if l.MaxIterations > 0 && cfg.MaxIterations > l.MaxIterations {
+		return fmt.Errorf("%d iterations exceed the limit of %d", cfg.MaxIterations, l.MaxIterations)
+	}
+	res := cfg.Estimate()
+	if l.MaxPixels > 0 && res.Pixels > l.MaxPixels {
+		return fmt.Errorf("%dx%d pixels exceed the limit of %d", cfg.Width, cfg.Height, l.MaxPixels)
+	}
+	if l.MaxSamples > 0 && res.Samples > l.MaxSamples {
+		return fmt.Errorf("%d samples exceed the limit of %d, lower samples or the size", res.Samples, l.MaxSamples)
+	}
+	if l.MaxGoroutines > 0 && res.Goroutines > l.MaxGoroutines {
+		return fmt.Errorf("mode %s starts %d goroutines, more than the limit of %d", cfg.Mode, res.Goroutines, l.MaxGoroutines)
+	}
+	return nil
+}
+
+var (
+	errQueueFull    = &statusError{http.StatusServiceUnavailable, errors.New("render queue is full")}
+	errQueueTimeout = &statusError{http.StatusServiceUnavailable, errors.New("timed out waiting for a render")}
+)
+
+// acquire waits for a render slot and returns the function releasing it. It
+// fails with 503 if the queue is full or the wait times out.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.tickets <- struct{}{}:
+	default:
+		return nil, errQueueFull
+	}
+
+	if l.QueueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.QueueTimeout)
+		defer cancel()
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() {
+			<-l.slots
+			<-l.tickets
+		}, nil
+	case <-ctx.Done():
+		<-l.tickets
+		return nil, errQueueTimeout
+	}
+}
+
+// rateLimit rejects requests of clients over the rate with 429
+func (l *limiter) rateLimit(next http.Handler) http.Handler {
+	return l.clients.limit(next)
+}
+
+// tileRateLimit rejects tile requests of clients over the tile rate with 429
+func (l *limiter) tileRateLimit(next http.Handler) http.Handler {
+	return l.tiles.limit(next)
+}
+
+// limit rejects requests of clients out of tokens with 429, a nil l allows
+// every request
+func (l *rateLimiter) limit(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if wait, ok := l.allow(client, time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter keeps a token bucket per client
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// allow takes a token of client, or returns the time until one is available
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// buckets that refilled are the same as new ones
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) > full {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > full {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
@@ -1,50 +1,65 @@
-package main
+// Package web serves rendered images over HTTP, with query parameters for
+// every field of the config.
+package web
 
 import (
-	"errors"
-	"flag"
+	"bytes"
+	"context"
 	"fmt"
 	"image"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AksAman/mandelbrot/imageio"
 	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/output"
 	"github.com/AksAman/mandelbrot/utils"
-	"github.com/disintegration/imaging"
 )
 
-var (
-	out        = flag.String("out", ".png", "Name of the output file with extension")
-	iterations = flag.Int("iter", mandelbrot.DefaultConfig.MaxIterations, "Max Iterations")
-	width      = flag.Int("width", mandelbrot.DefaultConfig.Width, "Width of the image")
-	height     = flag.Int("height", mandelbrot.DefaultConfig.Height, "Height of the image")
-	threshold  = flag.Float64("threshold", mandelbrot.DefaultConfig.Threshold, "Threshold for the mandelbrot set")
-	workers    = flag.Int("workers", mandelbrot.DefaultConfig.Workers, "Number of workers to use")
-	scale      = flag.Int("scale", 1, "Scale of the image")
-	mode       = flag.String("mode", string(mandelbrot.DefaultConfig.Mode), "Mode of the image (options: seq, pixel, row, workers)")
-	port       = flag.String("port", "8080", "Port to run the server on")
-)
-
-func main() {
-	flag.Parse()
+// Options holds the defaults for requests that leave parameters out.
+type Options struct {
+	// Defaults is the config of a request without query parameters
+	Defaults mandelbrot.Config
+	// Out names images saved with save=true, its extension is the default
+	// format of responses
+	Out         string
+	Quality     int
+	Compression imageio.Compression
+	Limits      Limits
+	// Cache keeps rendered images, nil renders every request
+	Cache *Cache
+	// CacheMaxAge is how long clients may use cached images without
+	// revalidating, 0 makes them revalidate every time
+	CacheMaxAge time.Duration
+}
 
+// Handler returns the handler of the server, with every request logged and
+// renders bounded by opts.Limits.
+func Handler(opts Options) http.Handler {
+	limits := newLimiter(opts.Limits)
 	mux := http.NewServeMux()
-	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "Hello, %q", r.URL.Path)
-	}))
-	mux.HandleFunc("/mandelbrot", mandelbrotHandler)
-
-	addr := fmt.Sprintf(":%s", *port)
-	log.Printf("Server running on port %s", addr)
-	http.ListenAndServe(addr, loggerMiddleware(mux))
+	explorer := explorerHandler(opts)
+	mux.Handle("/", explorer)
+	mux.Handle("/static/", explorer)
+	mux.Handle("/mandelbrot", limits.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mandelbrotHandler(w, r, opts, limits)
+	})))
+	mux.Handle("/api/render", limits.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiHandler(w, r, opts, limits)
+	})))
+	mux.Handle(rendersPath, limits.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rendersHandler(w, r, opts, limits)
+	})))
+	// map viewers request a screen of tiles at once, so tiles have a rate
+	// of their own with a larger burst
+	mux.Handle("/tiles/", limits.tileRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tilesHandler(w, r, opts, limits)
+	})))
+	return loggerMiddleware(mux)
 }
 
 func loggerMiddleware(next http.Handler) http.Handler {
@@ -59,112 +74,185 @@ func loggerMiddleware(next http.Handler) http.Handler {
 	)
 }
 
-func mandelbrotHandler(w http.ResponseWriter, r *http.Request) {
-
-	width := utils.GetQueryParam(r, "width", *width)
-	height := utils.GetQueryParam(r, "height", *height)
-	iterations := utils.GetQueryParam(r, "iterations", *iterations)
-	threshold := utils.GetQueryParam(r, "threshold", *threshold)
-	workers := utils.GetQueryParam(r, "workers", *workers)
-	scale := utils.GetQueryParam(r, "scale", *scale)
-	mode := utils.GetQueryParam(r, "mode", *mode)
-	out := utils.GetQueryParam(r, "out", *out)
-	zoom := utils.GetQueryParam(r, "zoom", 1.)
-	smooth := utils.GetQueryParam(r, "smooth", false)
-	offsetX := utils.GetQueryParam(r, "offsetX", 0.)
-	offsetY := utils.GetQueryParam(r, "offsetY", 0.)
-	hue := utils.GetQueryParam(r, "hue", 0.)
+func mandelbrotHandler(w http.ResponseWriter, r *http.Request, opts Options, limits *limiter) {
+	out := utils.GetQueryParam(r, "out", filepath.Ext(opts.Out))
+	compression := utils.GetQueryParam(r, "compression", string(opts.Compression))
 	save := utils.GetQueryParam(r, "save", false)
 
-	config := mandelbrot.Config{
-		Width:         width,
-		Height:        height,
-		Threshold:     threshold,
-		Workers:       workers,
-		Scale:         scale,
-		Mode:          mandelbrot.Mode(mode),
-		MaxIterations: iterations,
-		Zoom:          zoom,
-		Smooth:        smooth,
-		OffsetX:       offsetX,
-		OffsetY:       offsetY,
-		HueOffset:     hue,
-	}
-
-	img, err := mandelbrot.Create(config)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	config := configFromQuery(r, opts.Defaults)
+	encodeOpts := imageio.Options{
+		Quality:     opts.Quality,
+		Compression: imageio.Compression(compression),
+	}
+	if !save {
+		serveImage(w, r, config, out, encodeOpts, opts, limits, writeError)
 		return
 	}
 
-	img = imaging.AdjustContrast(img, 20)
-	img = imaging.AdjustBrightness(img, 20)
+	// saved images are always rendered, the file needs the image
+	if err := checkConfig(config, limits); err != nil {
+		writeError(w, err)
+		return
+	}
+	img, finalConfig, err := render(r.Context(), config, limits)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	encodeOpts.Metadata = output.Metadata(finalConfig)
 
-	err = EncodeImage(w, img, out)
+	err = EncodeImage(w, img, out, encodeOpts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if save {
-		filename := GetFilenameWithFlags("./img/web-colored.jpg", config)
-		err = SaveImage(img, filename)
-		if err == nil {
-			log.Println("Saved image to", filename)
+	filename := output.Filename(opts.Out, finalConfig)
+	err = output.Save(img, filename, finalConfig, encodeOpts)
+	if err == nil {
+		log.Println("Saved image to", filename)
+	}
+}
+
+// serveImage responds with config rendered and encoded as ext, or with fail
+// if it cannot be. With a cache the image is looked up first and responses
+// carry an ETag, so clients can revalidate with If-None-Match and get a 304.
+func serveImage(w http.ResponseWriter, r *http.Request, config mandelbrot.Config, ext string, encodeOpts imageio.Options, opts Options, limits *limiter, fail func(http.ResponseWriter, error)) {
+	if err := checkConfig(config, limits); err != nil {
+		fail(w, err)
+		return
+	}
+
+	if opts.Cache == nil {
+		img, finalConfig, err := render(r.Context(), config, limits)
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		encodeOpts.Metadata = output.Metadata(finalConfig)
+		if err := EncodeImage(w, img, ext, encodeOpts); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
+		return
+	}
 
+	key, err := cacheKey(config, ext, encodeOpts)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	etag := `"` + key + `"`
+	w.Header().Set("ETag", etag)
+	if opts.CacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(opts.CacheMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-}
 
-func EncodeImage(w http.ResponseWriter, img image.Image, extension string) (err error) {
-	switch extension {
-	case ".png":
-		err = png.Encode(w, img)
-	case ".jpg", ".jpeg":
-		err = jpeg.Encode(w, img, &jpeg.Options{Quality: 100})
-	case ".gif":
-		err = gif.Encode(w, img, &gif.Options{NumColors: 256})
-	default:
-		err = errors.New("Unsupported image format: " + extension)
+	data, hit, err := opts.Cache.Get(key, func() ([]byte, error) {
+		// other requests may wait for this render, so it goes on if the
+		// client leaves
+		img, finalConfig, err := render(context.Background(), config, limits)
+		if err != nil {
+			return nil, err
+		}
+		encodeOpts.Metadata = output.Metadata(normalizeConfig(finalConfig))
+		var buf bytes.Buffer
+		if err := imageio.Encode(&buf, img, ext, encodeOpts); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		fail(w, err)
+		return
 	}
 
-	return err
+	if contentType := imageio.ContentType(ext); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if hit {
+		w.Header().Set("X-Cache", "hit")
+	} else {
+		w.Header().Set("X-Cache", "miss")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
 }
 
-func GetFilenameWithFlags(filename string, config mandelbrot.Config) string {
-	ext := filepath.Ext(filename)
-	filenameWithoutExt := strings.Split(filename, ext)[0]
-	return filenameWithoutExt + "#" + getFlags(config) + ext
+// etagMatch reports whether the If-None-Match header lists etag
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
 }
 
-func getFlags(config mandelbrot.Config) string {
-	return strings.Join([]string{
-		"i=" + strconv.Itoa(config.MaxIterations),
-		"t=" + fmt.Sprintf("%v", config.Threshold),
-		"z=" + fmt.Sprintf("%v", config.Zoom),
-		// "h=" + fmt.Sprintf("%v", *hueOffset),
-		"x=" + fmt.Sprintf("%v", config.OffsetX),
-		"y=" + fmt.Sprintf("%v", config.OffsetY),
-	}, "_")
+// checkConfig returns a 400 error if config is invalid or exceeds the limits
+func checkConfig(config mandelbrot.Config, limits *limiter) error {
+	err := config.Validate()
+	if err == nil {
+		err = config.ValidateSize()
+	}
+	if err == nil {
+		err = limits.check(config)
+	}
+	if err != nil {
+		return &statusError{http.StatusBadRequest, err}
+	}
+	return nil
 }
 
-func SaveImage(img image.Image, filename string) error {
-	f, err := os.Create(filename)
+// render renders config once a render slot is free
+func render(ctx context.Context, config mandelbrot.Config, limits *limiter) (image.Image, mandelbrot.Config, error) {
+	release, err := limits.acquire(ctx)
 	if err != nil {
-		return err
+		return nil, config, err
 	}
-	defer f.Close()
+	defer release()
+	return output.ServerLook.Render(config)
+}
 
-	switch ext := filepath.Ext(filename); ext {
-	case ".png":
-		err = png.Encode(f, img)
-	case ".jpg", ".jpeg":
-		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 75})
-	case ".gif":
-		err = gif.Encode(f, img, &gif.Options{NumColors: 256})
-	default:
-		err = errors.New("Unsupported image format: " + ext)
+// configFromQuery reads the config of a request, with defaults for the
+// parameters it leaves out
+func configFromQuery(r *http.Request, defaults mandelbrot.Config) mandelbrot.Config {
+	return mandelbrot.Config{
+		Width:         utils.GetQueryParam(r, "width", defaults.Width),
+		Height:        utils.GetQueryParam(r, "height", defaults.Height),
+		Threshold:     utils.GetQueryParam(r, "threshold", defaults.Threshold),
+		Workers:       utils.GetQueryParam(r, "workers", defaults.Workers),
+		Scale:         utils.GetQueryParam(r, "scale", defaults.Scale),
+		Mode:          mandelbrot.Mode(utils.GetQueryParam(r, "mode", string(defaults.Mode))),
+		MaxIterations: utils.GetQueryParam(r, "iterations", defaults.MaxIterations),
+		XScale:        defaults.XScale,
+		YScale:        defaults.YScale,
+		Zoom:          utils.GetQueryParam(r, "zoom", defaults.Zoom),
+		Smooth:        utils.GetQueryParam(r, "smooth", defaults.Smooth),
+		OffsetX:       utils.GetQueryParam(r, "offsetX", defaults.OffsetX),
+		OffsetY:       utils.GetQueryParam(r, "offsetY", defaults.OffsetY),
+		HueOffset:     utils.GetQueryParam(r, "hue", defaults.HueOffset),
+		Palette:       mandelbrot.Palette(utils.GetQueryParam(r, "palette", string(defaults.Palette))),
+		Interior:      mandelbrot.Interior(utils.GetQueryParam(r, "interior", string(defaults.Interior))),
+		Samples:       utils.GetQueryParam(r, "samples", defaults.Samples),
+		Sampling:      mandelbrot.Sampling(utils.GetQueryParam(r, "sampling", string(defaults.Sampling))),
+		Filter:        mandelbrot.Filter(utils.GetQueryParam(r, "filter", string(defaults.Filter))),
+		Adaptive:      utils.GetQueryParam(r, "adaptive", defaults.Adaptive),
+		Depth:         mandelbrot.Depth(utils.GetQueryParam(r, "depth", int(defaults.Depth))),
 	}
+}
 
-	return err
+func EncodeImage(w http.ResponseWriter, img image.Image, extension string, opts imageio.Options) error {
+	if contentType := imageio.ContentType(extension); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	return imageio.Encode(w, img, extension, opts)
 }
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/AksAman/mandelbrot/imageio"
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/utils"
+)
+
+// TileSize is the width and height of tiles in pixels
+const TileSize = 256
+
+// MaxTileIterations caps the iterations of a tile. A screen of tiles renders
+// many pixels at once, so tiles are also never supersampled.
+const MaxTileIterations = 10000
+
+// MaxTileZoom is the deepest tile zoom level. Below it float64 can no longer
+// tell the pixels of a tile apart.
+const MaxTileZoom = 44
+
+// tileWorld is the square of the complex plane covered by the single tile of
+// zoom level 0, around the whole set
+var tileWorld = struct{ x, y, size float64 }{x: -2.5, y: -2, size: 4}
+
+// TileConfig returns the config rendering tile (x, y) of zoom level z of a
+// web map, in which level z divides the plane into 2^z by 2^z tiles. Tile
+// rows go down the image like the rows of a render. The view fields of
+// config are replaced, supersampling is turned off and iterations are capped
+// at MaxTileIterations, the others such as colors are kept.
+func TileConfig(config mandelbrot.Config, z, x, y int) mandelbrot.Config {
+	size := tileWorld.size / float64(uint64(1)<<z)
+	config.Width, config.Height, config.Scale = TileSize, TileSize, 1
+	config.Samples, config.Adaptive = 1, 0
+	config.MaxIterations = utils.Min(config.MaxIterations, MaxTileIterations)
+	config.Zoom, config.OffsetX, config.OffsetY = 1, 0, 0
+	config.XScale = &mandelbrot.SetScale{
+		Min: tileWorld.x + float64(x)*size,
+		Max: tileWorld.x + float64(x+1)*size,
+	}
+	config.YScale = &mandelbrot.SetScale{
+		Min: tileWorld.y + float64(y)*size,
+		Max: tileWorld.y + float64(y+1)*size,
+	}
+	return config
+}
+
+// parseTile parses z/x/y.ext, returning false unless the tile exists
+func parseTile(tile string) (z, x, y int, ext string, ok bool) {
+	ext = path.Ext(tile)
+	parts := strings.Split(strings.TrimSuffix(tile, ext), "/")
+	if len(parts) != 3 || imageio.ContentType(ext) == "" {
+		return 0, 0, 0, "", false
+	}
+
+	coords := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, 0, 0, "", false
+		}
+		coords[i] = n
+	}
+	z, x, y = coords[0], coords[1], coords[2]
+	if z > MaxTileZoom || x >= 1<<z || y >= 1<<z {
+		return 0, 0, 0, "", false
+	}
+	return z, x, y, ext, true
+}
+
+// tilesHandler serves /tiles/{z}/{x}/{y}.png. Query parameters other than
+// the view ones apply as for /mandelbrot, e.g. iterations and hue.
+func tilesHandler(w http.ResponseWriter, r *http.Request, opts Options, limits *limiter) {
+	z, x, y, ext, ok := parseTile(strings.TrimPrefix(r.URL.Path, "/tiles/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	config := TileConfig(configFromQuery(r, opts.Defaults), z, x, y)
+	serveImage(w, r, config, ext, imageio.Options{
+		Quality:     opts.Quality,
+		Compression: opts.Compression,
+	}, opts, limits, writeError)
+}
@@ -0,0 +1,54 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AksAman/mandelbrot/mandelbrot"
+	"github.com/AksAman/mandelbrot/web"
+)
+
+func TestTileConfigCost(t *testing.T) {
+	config := web.TileConfig(mandelbrot.Config{MaxIterations: 1e6, Samples: 16, Adaptive: 0.1, Scale: 4}, 3, 1, 2)
+	if config.Width != web.TileSize || config.Height != web.TileSize || config.Scale != 1 {
+		t.Errorf("size %dx%d scale %d, want %dx%d scale 1", config.Width, config.Height, config.Scale, web.TileSize, web.TileSize)
+	}
+	if config.Samples != 1 || config.Adaptive != 0 {
+		t.Errorf("samples %d adaptive %g, want no supersampling", config.Samples, config.Adaptive)
+	}
+	if config.MaxIterations != web.MaxTileIterations {
+		t.Errorf("%d iterations, want %d", config.MaxIterations, web.MaxTileIterations)
+	}
+	if config := web.TileConfig(mandelbrot.Config{MaxIterations: 500}, 0, 0, 0); config.MaxIterations != 500 {
+		t.Errorf("%d iterations, want 500 under the cap", config.MaxIterations)
+	}
+}
+
+func TestTileRateLimit(t *testing.T) {
+	handler := web.Handler(web.Options{
+		Defaults: mandelbrot.Config{MaxIterations: 10},
+		Out:      ".png",
+		Limits:   web.Limits{Rate: 0.001, Burst: 1, TileRate: 0.001, TileBurst: 3},
+	})
+	get := func(path string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// the tiles have their own burst, and do not use up the one of renders
+	for i := 0; i < 3; i++ {
+		if code := get(fmt.Sprintf("/tiles/2/%d/1.png", i)); code != http.StatusOK {
+			t.Fatalf("tile %d: status %d", i, code)
+		}
+	}
+	if code := get("/tiles/2/3/1.png"); code != http.StatusTooManyRequests {
+		t.Errorf("tile past the burst: status %d, want 429", code)
+	}
+	if code := get("/mandelbrot?width=8&height=8"); code != http.StatusOK {
+		t.Errorf("render after the tiles: status %d", code)
+	}
+}